@@ -0,0 +1,94 @@
+// Package bird talks to the BIRD control socket (birdc's protocol) to
+// reconfigure the daemon and query session state.
+package bird
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const dialTimeout = 5 * time.Second
+
+// RunCommand sends a single command to the BIRD control socket and returns
+// an error if BIRD reports one.
+func RunCommand(command, socket string) error {
+	output, err := query(command, socket)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		// BIRD error replies are a 4-digit code >= 8000 followed by a
+		// space and the message, e.g. "8001 Syntax error".
+		if len(line) >= 4 && (line[0] == '8' || line[0] == '9') && line[1] >= '0' && line[1] <= '9' {
+			return fmt.Errorf("birdc %s: %s", command, line)
+		}
+	}
+
+	return nil
+}
+
+// ShowProtocols runs `show protocols` and returns BIRD's raw table output,
+// one protocol per line.
+func ShowProtocols(socket string) (string, error) {
+	return query("show protocols", socket)
+}
+
+// query opens a connection to the BIRD control socket, sends command, and
+// returns every line BIRD sends back until it closes the connection or
+// sends the final status line of its reply (e.g. "0000 ").
+func query(command, socket string) (string, error) {
+	conn, err := net.DialTimeout("unix", socket, dialTimeout)
+	if err != nil {
+		return "", fmt.Errorf("dial bird socket %s: %w", socket, err)
+	}
+	defer conn.Close() //nolint:errcheck
+
+	if err := conn.SetDeadline(time.Now().Add(dialTimeout)); err != nil {
+		return "", err
+	}
+
+	scanner := bufio.NewScanner(conn)
+
+	// BIRD sends a "0001 BIRD vX.X.X ready." greeting as soon as the
+	// connection opens, before any command is sent. Discard it so the
+	// reply loop below doesn't mistake it for the end of our command's
+	// reply.
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return "", fmt.Errorf("read bird greeting: %w", err)
+		}
+		return "", fmt.Errorf("read bird greeting: connection closed")
+	}
+
+	if _, err := fmt.Fprintf(conn, "%s\n", command); err != nil {
+		return "", fmt.Errorf("write bird command: %w", err)
+	}
+
+	var lines []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		lines = append(lines, line)
+
+		// A reply ends with a line whose 4-digit code is followed by a
+		// space (e.g. "0000 ", "0013 Daemon is up", "8001 Syntax
+		// error"); continuation lines use "-" after the code instead
+		// ("0002-Reading configuration ...") or start with a space.
+		final := len(line) >= 4 && (len(line) == 4 || line[4] == ' ')
+		for i := 0; final && i < 4; i++ {
+			final = line[i] >= '0' && line[i] <= '9'
+		}
+		if final {
+			break
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("read bird reply: %w", err)
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,72 @@
+package bird
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+// startFakeBird listens on a unix socket and accepts a single client: it
+// sends the BIRD connect greeting, writes reply once after the first line it
+// receives, then closes the connection.
+func startFakeBird(t *testing.T, reply string) string {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "bird.ctl")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close() //nolint:errcheck
+
+		fmt.Fprintf(conn, "0001 BIRD 2.0.8 ready.\n")
+
+		scanner := bufio.NewScanner(conn)
+		if scanner.Scan() {
+			fmt.Fprint(conn, reply)
+		}
+	}()
+
+	return socket
+}
+
+func TestQuerySkipsGreeting(t *testing.T) {
+	socket := startFakeBird(t, "1000-name    proto    table    state  since       info\n0000 \n")
+
+	output, err := query("show protocols", socket)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if output == "0001 BIRD 2.0.8 ready." {
+		t.Fatalf("query returned the greeting instead of the real reply: %q", output)
+	}
+	if output != "1000-name    proto    table    state  since       info\n0000 " {
+		t.Fatalf("query = %q, want the reply body without the greeting", output)
+	}
+}
+
+func TestRunCommandDetectsErrorAfterGreeting(t *testing.T) {
+	socket := startFakeBird(t, "8001 Syntax error\n")
+
+	err := RunCommand("configure", socket)
+	if err == nil {
+		t.Fatal("expected an error for an 8xxx BIRD reply, got nil")
+	}
+}
+
+func TestRunCommandSucceedsOnOkReply(t *testing.T) {
+	socket := startFakeBird(t, "0002-Reading configuration from /etc/bird.conf\n0020 Configuration ok\n")
+
+	if err := RunCommand("configure", socket); err != nil {
+		t.Fatalf("RunCommand: %v", err)
+	}
+}
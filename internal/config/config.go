@@ -0,0 +1,164 @@
+// Package config defines bcg's YAML/TOML/JSON configuration schema and
+// loads it from disk.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// Processors toggles individual peer-processor pipeline stages off. Every
+// field defaults to false (i.e. the stage runs).
+type Processors struct {
+	DisablePeeringDB bool `yaml:"disable_peeringdb" toml:"disable_peeringdb" json:"disable_peeringdb"`
+	DisableIRR       bool `yaml:"disable_irr" toml:"disable_irr" json:"disable_irr"`
+}
+
+// RPKI configures the RTR cache bcg validates IRR prefixes against. Host
+// empty means RPKI validation is disabled.
+type RPKI struct {
+	Host            string        `yaml:"host" toml:"host" json:"host"`
+	Port            uint16        `yaml:"port" toml:"port" json:"port"`
+	RefreshInterval time.Duration `yaml:"refresh_interval" toml:"refresh_interval" json:"refresh_interval"`
+}
+
+// VRRPInstance describes one keepalived VRRP instance to render.
+type VRRPInstance struct {
+	Name      string   `yaml:"name" toml:"name" json:"name"`
+	Interface string   `yaml:"interface" toml:"interface" json:"interface"`
+	VRID      uint     `yaml:"vrid" toml:"vrid" json:"vrid"`
+	Priority  uint     `yaml:"priority" toml:"priority" json:"priority"`
+	VIPs      []string `yaml:"vips" toml:"vips" json:"vips"`
+}
+
+// ProcessorStatus records the outcome of the most recent run of a single
+// pipeline stage for a peer.
+type ProcessorStatus struct {
+	Err error     `json:"error,omitempty"`
+	At  time.Time `json:"at"`
+}
+
+// Peer is one configured BGP session.
+type Peer struct {
+	Name  string `yaml:"-" toml:"-" json:"-"`
+	Asn   uint   `yaml:"asn" toml:"asn" json:"asn"`
+	Type  string `yaml:"type" toml:"type" json:"type"`
+	AsSet string `yaml:"as-set" toml:"as-set" json:"as-set"`
+
+	ImportLimit4 uint `yaml:"import-limit4" toml:"import-limit4" json:"import-limit4"`
+	ImportLimit6 uint `yaml:"import-limit6" toml:"import-limit6" json:"import-limit6"`
+
+	PrefixSet4 []string `yaml:"-" toml:"-" json:"-"`
+	PrefixSet6 []string `yaml:"-" toml:"-" json:"-"`
+
+	LocalPref          uint     `yaml:"local-pref" toml:"local-pref" json:"local-pref"`
+	ExportDefault      bool     `yaml:"export-default" toml:"export-default" json:"export-default"`
+	NoSpecifics        bool     `yaml:"no-specifics" toml:"no-specifics" json:"no-specifics"`
+	AllowBlackholes    bool     `yaml:"allow-blackholes" toml:"allow-blackholes" json:"allow-blackholes"`
+	Communities        []string `yaml:"communities" toml:"communities" json:"communities"`
+	LargeCommunities   []string `yaml:"large-communities" toml:"large-communities" json:"large-communities"`
+	Prepends           uint     `yaml:"prepends" toml:"prepends" json:"prepends"`
+	Multihop           bool     `yaml:"multihop" toml:"multihop" json:"multihop"`
+	Passive            bool     `yaml:"passive" toml:"passive" json:"passive"`
+	Disabled           bool     `yaml:"disabled" toml:"disabled" json:"disabled"`
+	EnforceFirstAs     bool     `yaml:"enforce-first-as" toml:"enforce-first-as" json:"enforce-first-as"`
+	EnforcePeerNexthop bool     `yaml:"enforce-peer-nexthop" toml:"enforce-peer-nexthop" json:"enforce-peer-nexthop"`
+	NeighborIPs        []string `yaml:"neighbors" toml:"neighbors" json:"neighbors"`
+
+	GeneratePassword bool     `yaml:"generate_password" toml:"generate_password" json:"generate_password"`
+	Passwords        []string `yaml:"-" toml:"-" json:"-"`
+
+	QueryTime string `yaml:"-" toml:"-" json:"-"`
+
+	// Status holds the most recent result of every pipeline stage that
+	// has run for this peer, keyed by processor name (e.g. "peeringdb").
+	// RenderProcessor and main's run() check the "peeringdb" and "irr"
+	// entries to skip a peer; the /statusz page does not display it.
+	Status map[string]*ProcessorStatus `yaml:"-" toml:"-" json:"-"`
+}
+
+// SetProcessorStatus records the outcome of running the named processor
+// against this peer, and refreshes QueryTime on success so the UI always
+// shows the time of the last successful operation.
+func (p *Peer) SetProcessorStatus(name string, err error) {
+	if p.Status == nil {
+		p.Status = map[string]*ProcessorStatus{}
+	}
+	p.Status[name] = &ProcessorStatus{Err: err, At: time.Now()}
+
+	if err == nil {
+		p.QueryTime = time.Now().Format(time.RFC1123)
+	}
+}
+
+// Global is the top level bcg configuration document.
+type Global struct {
+	Prefixes []string `yaml:"prefixes" toml:"prefixes" json:"prefixes"`
+
+	OriginSet4 []string `yaml:"-" toml:"-" json:"-"`
+	OriginSet6 []string `yaml:"-" toml:"-" json:"-"`
+
+	IrrDb            string           `yaml:"irrdb" toml:"irrdb" json:"irrdb"`
+	Peers            map[string]*Peer `yaml:"peers" toml:"peers" json:"peers"`
+	VRRPInstances    []VRRPInstance   `yaml:"vrrp" toml:"vrrp" json:"vrrp"`
+	RPKI             RPKI             `yaml:"rpki" toml:"rpki" json:"rpki"`
+	GeneratePassword bool             `yaml:"generate_password" toml:"generate_password" json:"generate_password"`
+	Processors       Processors       `yaml:"processors" toml:"processors" json:"processors"`
+}
+
+// Wrapper bundles a single peer with the global config for templates that
+// need both (e.g. the per-peer BIRD config, which references global
+// origin sets and RPKI tables).
+type Wrapper struct {
+	Peer   Peer
+	Config Global
+}
+
+// Load reads a bcg configuration file in YAML, TOML, or JSON format, picked
+// by the file extension.
+func Load(path string) (*Global, error) {
+	var global Global
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yml", ".yaml":
+		if err := unmarshalFile(path, &global, yaml.Unmarshal); err != nil {
+			return nil, err
+		}
+	case ".toml":
+		if _, err := toml.DecodeFile(path, &global); err != nil {
+			return nil, fmt.Errorf("parse TOML config %s: %w", path, err)
+		}
+	case ".json":
+		if err := unmarshalFile(path, &global, json.Unmarshal); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %q (want .yml, .yaml, .toml, or .json)", ext)
+	}
+
+	for name, peer := range global.Peers {
+		peer.Name = name
+	}
+
+	return &global, nil
+}
+
+func unmarshalFile(path string, out interface{}, unmarshal func([]byte, interface{}) error) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config %s: %w", path, err)
+	}
+
+	if err := unmarshal(data, out); err != nil {
+		return fmt.Errorf("parse config %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+// Package daemon keeps bcg resident: it re-runs the processor pipeline on an
+// interval (and on SIGHUP or a config file change), and exposes a
+// /statusz, /healthz and /metrics HTTP server for operational visibility.
+package daemon
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/natesales/bcg/internal/config"
+)
+
+// Daemon re-runs Reload on a schedule and serves status/metrics over HTTP.
+type Daemon struct {
+	ConfigPath string
+	Interval   time.Duration
+	Listen     string
+	Socket     string
+
+	// Reload performs one full config-load + pipeline pass and returns the
+	// resulting global config.
+	Reload func() (*config.Global, error)
+
+	mu         sync.RWMutex
+	current    *config.Global
+	lastReload time.Time
+	configMod  time.Time
+}
+
+// New creates a Daemon. interval, listen and socket follow the --interval,
+// --listen and --socket flags.
+func New(configPath string, interval time.Duration, listen string, socket string, reload func() (*config.Global, error)) *Daemon {
+	return &Daemon{
+		ConfigPath: configPath,
+		Interval:   interval,
+		Listen:     listen,
+		Socket:     socket,
+		Reload:     reload,
+	}
+}
+
+// Run blocks, re-rendering on a timer, SIGHUP, or config file change, while
+// serving the status HTTP server, until ctx is cancelled.
+func (d *Daemon) Run(ctx context.Context) error {
+	if err := d.reload(); err != nil {
+		log.Errorf("daemon: initial reload: %v", err)
+	}
+
+	srv := &http.Server{Addr: d.Listen, Handler: d.mux()}
+	go func() {
+		log.Infof("daemon: listening on %s", d.Listen)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Errorf("daemon: http server: %v", err)
+		}
+	}()
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	// Poll the config file's mtime rather than using inotify directly so
+	// we don't need an extra fsnotify goroutine/fd per watch; good enough
+	// at daemon reload cadence.
+	configPoll := time.NewTicker(5 * time.Second)
+	defer configPoll.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			return srv.Shutdown(shutdownCtx)
+
+		case <-ticker.C:
+			log.Debug("daemon: interval reload")
+			if err := d.reload(); err != nil {
+				log.Errorf("daemon: reload: %v", err)
+			}
+
+		case <-sighup:
+			log.Info("daemon: SIGHUP received, reloading")
+			if err := d.reload(); err != nil {
+				log.Errorf("daemon: reload: %v", err)
+			}
+
+		case <-configPoll.C:
+			if d.configChanged() {
+				log.Info("daemon: config file changed, reloading")
+				if err := d.reload(); err != nil {
+					log.Errorf("daemon: reload: %v", err)
+				}
+			}
+		}
+	}
+}
+
+func (d *Daemon) reload() error {
+	global, err := d.Reload()
+	if err != nil {
+		return err
+	}
+
+	if stat, statErr := os.Stat(d.ConfigPath); statErr == nil {
+		d.mu.Lock()
+		d.configMod = stat.ModTime()
+		d.mu.Unlock()
+	}
+
+	d.mu.Lock()
+	d.current = global
+	d.lastReload = time.Now()
+	d.mu.Unlock()
+
+	return nil
+}
+
+func (d *Daemon) configChanged() bool {
+	stat, err := os.Stat(d.ConfigPath)
+	if err != nil {
+		return false
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return stat.ModTime().After(d.configMod)
+}
+
+// snapshot returns the most recently rendered config, and the time it was
+// rendered at.
+func (d *Daemon) snapshot() (*config.Global, time.Time) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.current, d.lastReload
+}
@@ -0,0 +1,151 @@
+package daemon
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/natesales/bcg/internal/bird"
+)
+
+func (d *Daemon) mux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/statusz", d.statuszHandler)
+	mux.HandleFunc("/healthz", d.healthzHandler)
+	mux.HandleFunc("/metrics", d.metricsHandler)
+	return mux
+}
+
+func (d *Daemon) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	global, _ := d.snapshot()
+	if global == nil {
+		http.Error(w, "no successful reload yet", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ok")
+}
+
+var statuszTemplate = template.Must(template.New("statusz").Parse(`<!DOCTYPE html>
+<html>
+<head><title>bcg statusz</title></head>
+<body>
+<h1>bcg statusz</h1>
+<p>Last reload: {{.LastReload}}</p>
+<table border="1" cellpadding="4">
+<tr><th>Peer</th><th>ASN</th><th>AS-SET</th><th>Max Pfx4</th><th>Max Pfx6</th><th>Last query</th><th>Session</th></tr>
+{{range $name, $peer := .Peers}}
+<tr>
+<td>{{$name}}</td>
+<td>{{$peer.Asn}}</td>
+<td>{{$peer.AsSet}}</td>
+<td>{{$peer.ImportLimit4}}</td>
+<td>{{$peer.ImportLimit6}}</td>
+<td>{{$peer.QueryTime}}</td>
+<td>{{index $.Sessions $peer.Name}}</td>
+</tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
+
+type statuszData struct {
+	LastReload string
+	Peers      map[string]*peerView
+	Sessions   map[string]string
+}
+
+type peerView struct {
+	Name         string
+	Asn          uint
+	AsSet        string
+	ImportLimit4 uint
+	ImportLimit6 uint
+	QueryTime    string
+}
+
+func (d *Daemon) statuszHandler(w http.ResponseWriter, r *http.Request) {
+	global, lastReload := d.snapshot()
+	if global == nil {
+		http.Error(w, "no successful reload yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	peers := make(map[string]*peerView, len(global.Peers))
+	for name, peer := range global.Peers {
+		peers[name] = &peerView{
+			Name:         peer.Name,
+			Asn:          peer.Asn,
+			AsSet:        peer.AsSet,
+			ImportLimit4: peer.ImportLimit4,
+			ImportLimit6: peer.ImportLimit6,
+			QueryTime:    peer.QueryTime,
+		}
+	}
+
+	data := statuszData{
+		LastReload: lastReload.Format("Mon, 02 Jan 2006 15:04:05 MST"),
+		Peers:      peers,
+		Sessions:   d.sessionStates(),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := statuszTemplate.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// sessionStates pulls `show protocols` from BIRD and maps peer name to its
+// reported session state (e.g. "Established", "Idle").
+func (d *Daemon) sessionStates() map[string]string {
+	states := map[string]string{}
+
+	output, err := bird.ShowProtocols(d.Socket)
+	if err != nil {
+		return states
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 || fields[1] != "BGP" {
+			continue
+		}
+		states[fields[0]] = fields[5]
+	}
+
+	return states
+}
+
+func (d *Daemon) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	global, lastReload := d.snapshot()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	if global == nil {
+		return
+	}
+
+	sessions := d.sessionStates()
+
+	fmt.Fprintln(w, "# HELP bcg_last_reload_timestamp_seconds Unix timestamp of the last successful pipeline run")
+	fmt.Fprintln(w, "# TYPE bcg_last_reload_timestamp_seconds gauge")
+	fmt.Fprintf(w, "bcg_last_reload_timestamp_seconds %d\n", lastReload.Unix())
+
+	fmt.Fprintln(w, "# HELP bcg_peer_session_up 1 if the BIRD BGP session is Established, 0 otherwise")
+	fmt.Fprintln(w, "# TYPE bcg_peer_session_up gauge")
+	for name, peer := range global.Peers {
+		up := 0
+		if sessions[peer.Name] == "Established" {
+			up = 1
+		}
+		fmt.Fprintf(w, "bcg_peer_session_up{peer=%q,asn=%q}  %d\n", name, fmt.Sprint(peer.Asn), up)
+	}
+
+	fmt.Fprintln(w, "# HELP bcg_peer_prefixes_configured Number of IRR-derived prefixes configured for the peer")
+	fmt.Fprintln(w, "# TYPE bcg_peer_prefixes_configured gauge")
+	for name, peer := range global.Peers {
+		fmt.Fprintf(w, "bcg_peer_prefixes_configured{peer=%q,family=\"4\"} %d\n", name, len(peer.PrefixSet4))
+		fmt.Fprintf(w, "bcg_peer_prefixes_configured{peer=%q,family=\"6\"} %d\n", name, len(peer.PrefixSet6))
+	}
+}
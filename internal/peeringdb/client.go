@@ -0,0 +1,178 @@
+// Package peeringdb queries the PeeringDB API for a network's AS-SET and
+// max-prefix limits, with an optional on-disk response cache so a config
+// with many peers doesn't pay PeeringDB's round-trip (and rate limit) for
+// every single run.
+package peeringdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Data is the subset of a PeeringDB net record bcg cares about.
+type Data struct {
+	Name    string `json:"name"`
+	AsSet   string `json:"irr_as_set"`
+	MaxPfx4 uint   `json:"info_prefixes4"`
+	MaxPfx6 uint   `json:"info_prefixes6"`
+}
+
+type response struct {
+	Data []Data `json:"data"`
+}
+
+// DefaultTTL is how long a cached PeeringDB response is trusted before bcg
+// revalidates it with the API (via If-Modified-Since/ETag).
+const DefaultTTL = 24 * time.Hour
+
+// defaultBaseURL is PeeringDB's net lookup endpoint. Tests override baseURL
+// to point at an httptest.Server instead.
+const defaultBaseURL = "https://peeringdb.com/api/net"
+
+// Client queries PeeringDB, optionally caching responses to disk.
+type Client struct {
+	APIKey   string
+	CacheDir string
+	TTL      time.Duration
+
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient creates a PeeringDB client. If cacheDir is empty, caching is
+// disabled and every query hits the network.
+func NewClient(apiKey, cacheDir string, ttl time.Duration) *Client {
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+	return &Client{
+		APIKey:     apiKey,
+		CacheDir:   cacheDir,
+		TTL:        ttl,
+		baseURL:    defaultBaseURL,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// cacheEntry is what gets persisted to <CacheDir>/<asn>.json.
+type cacheEntry struct {
+	Data      Data      `json:"data"`
+	ETag      string    `json:"etag,omitempty"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+func (c *Client) cachePath(asn uint) string {
+	return filepath.Join(c.CacheDir, strconv.Itoa(int(asn))+".json")
+}
+
+func (c *Client) readCache(asn uint) (*cacheEntry, bool) {
+	if c.CacheDir == "" {
+		return nil, false
+	}
+
+	raw, err := ioutil.ReadFile(c.cachePath(asn))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return nil, false
+	}
+
+	return &entry, true
+}
+
+func (c *Client) writeCache(asn uint, entry cacheEntry) {
+	if c.CacheDir == "" {
+		return
+	}
+
+	if err := os.MkdirAll(c.CacheDir, 0755); err != nil {
+		return
+	}
+
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	_ = ioutil.WriteFile(c.cachePath(asn), raw, 0644)
+}
+
+// Query returns PeeringDB data for asn, using (and revalidating) the disk
+// cache when one is configured.
+func (c *Client) Query(asn uint) (Data, error) {
+	cached, haveCache := c.readCache(asn)
+	if haveCache && time.Since(cached.FetchedAt) < c.TTL {
+		return cached.Data, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"?asn="+strconv.Itoa(int(asn)), nil)
+	if err != nil {
+		return Data{}, err
+	}
+
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Api-Key "+c.APIKey)
+	}
+
+	if haveCache {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		req.Header.Set("If-Modified-Since", cached.FetchedAt.UTC().Format(http.TimeFormat))
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		if haveCache {
+			// Serve stale data rather than failing the peer outright.
+			return cached.Data, nil
+		}
+		return Data{}, err
+	}
+	defer res.Body.Close() //nolint:errcheck
+
+	if res.StatusCode == http.StatusNotModified && haveCache {
+		cached.FetchedAt = time.Now()
+		c.writeCache(asn, *cached)
+		return cached.Data, nil
+	}
+
+	if res.StatusCode != http.StatusOK {
+		if haveCache {
+			return cached.Data, nil
+		}
+		return Data{}, fmt.Errorf("PeeringDB returned %s for AS%d", res.Status, asn)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return Data{}, err
+	}
+
+	var parsed response
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return Data{}, err
+	}
+
+	if len(parsed.Data) < 1 {
+		return Data{}, fmt.Errorf("AS%d doesn't have a valid PeeringDB entry. Try import-valid or ask the network to update their account", asn)
+	}
+
+	entry := cacheEntry{
+		Data:      parsed.Data[0],
+		ETag:      res.Header.Get("ETag"),
+		FetchedAt: time.Now(),
+	}
+	c.writeCache(asn, entry)
+
+	return entry.Data, nil
+}
@@ -0,0 +1,157 @@
+package peeringdb
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCacheRoundTrip(t *testing.T) {
+	c := &Client{CacheDir: t.TempDir(), TTL: time.Hour}
+
+	if _, ok := c.readCache(65001); ok {
+		t.Fatal("readCache found an entry before any write")
+	}
+
+	entry := cacheEntry{
+		Data:      Data{Name: "Example Net", AsSet: "AS-EXAMPLE", MaxPfx4: 10, MaxPfx6: 5},
+		ETag:      `"abc123"`,
+		FetchedAt: time.Now(),
+	}
+	c.writeCache(65001, entry)
+
+	got, ok := c.readCache(65001)
+	if !ok {
+		t.Fatal("readCache found no entry after write")
+	}
+	if got.Data != entry.Data || got.ETag != entry.ETag {
+		t.Errorf("readCache = %+v, want %+v", got, entry)
+	}
+}
+
+func TestQueryServesFreshCacheWithoutNetwork(t *testing.T) {
+	c := NewClient("", t.TempDir(), time.Hour)
+
+	want := Data{Name: "Example Net", AsSet: "AS-EXAMPLE", MaxPfx4: 10, MaxPfx6: 5}
+	c.writeCache(65001, cacheEntry{Data: want, FetchedAt: time.Now()})
+
+	got, err := c.Query(65001)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if got != want {
+		t.Errorf("Query = %+v, want %+v", got, want)
+	}
+}
+
+func TestQueryWithoutCacheDirSkipsCache(t *testing.T) {
+	c := NewClient("", "", time.Hour)
+
+	if _, ok := c.readCache(65001); ok {
+		t.Fatal("readCache should never find an entry when CacheDir is empty")
+	}
+
+	c.writeCache(65001, cacheEntry{Data: Data{Name: "Example Net"}, FetchedAt: time.Now()})
+	if _, ok := c.readCache(65001); ok {
+		t.Fatal("writeCache should be a no-op when CacheDir is empty")
+	}
+}
+
+func TestQueryRevalidatesStaleCacheAndAcceptsNotModified(t *testing.T) {
+	var gotIfNoneMatch, gotIfModifiedSince string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotIfNoneMatch = r.Header.Get("If-None-Match")
+		gotIfModifiedSince = r.Header.Get("If-Modified-Since")
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer server.Close()
+
+	c := NewClient("", t.TempDir(), time.Hour)
+	c.baseURL = server.URL
+
+	stale := cacheEntry{
+		Data:      Data{Name: "Example Net", AsSet: "AS-EXAMPLE", MaxPfx4: 10, MaxPfx6: 5},
+		ETag:      `"abc123"`,
+		FetchedAt: time.Now().Add(-2 * time.Hour),
+	}
+	c.writeCache(65001, stale)
+
+	got, err := c.Query(65001)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if got != stale.Data {
+		t.Errorf("Query = %+v, want cached %+v", got, stale.Data)
+	}
+
+	if gotIfNoneMatch != stale.ETag {
+		t.Errorf("If-None-Match = %q, want %q", gotIfNoneMatch, stale.ETag)
+	}
+	if gotIfModifiedSince != stale.FetchedAt.UTC().Format(http.TimeFormat) {
+		t.Errorf("If-Modified-Since = %q, want %q", gotIfModifiedSince, stale.FetchedAt.UTC().Format(http.TimeFormat))
+	}
+
+	refreshed, ok := c.readCache(65001)
+	if !ok {
+		t.Fatal("readCache found no entry after revalidation")
+	}
+	if !refreshed.FetchedAt.After(stale.FetchedAt) {
+		t.Errorf("FetchedAt = %v, want refreshed to be after %v", refreshed.FetchedAt, stale.FetchedAt)
+	}
+}
+
+func TestQueryFallsBackToStaleCacheOnNetworkError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}))
+	server.Close() // closed immediately so requests fail to dial
+
+	c := NewClient("", t.TempDir(), time.Hour)
+	c.baseURL = server.URL
+
+	stale := cacheEntry{
+		Data:      Data{Name: "Example Net", AsSet: "AS-EXAMPLE", MaxPfx4: 10, MaxPfx6: 5},
+		FetchedAt: time.Now().Add(-2 * time.Hour),
+	}
+	c.writeCache(65001, stale)
+
+	got, err := c.Query(65001)
+	if err != nil {
+		t.Fatalf("Query: %v, want fallback to stale cache instead of an error", err)
+	}
+	if got != stale.Data {
+		t.Errorf("Query = %+v, want stale cached %+v", got, stale.Data)
+	}
+}
+
+func TestQueryFetchesFreshDataOnCacheMiss(t *testing.T) {
+	want := Data{Name: "Example Net", AsSet: "AS-EXAMPLE", MaxPfx4: 10, MaxPfx6: 5}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"fresh-etag"`)
+		_ = json.NewEncoder(w).Encode(response{Data: []Data{want}})
+	}))
+	defer server.Close()
+
+	c := NewClient("", t.TempDir(), time.Hour)
+	c.baseURL = server.URL
+
+	got, err := c.Query(65001)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if got != want {
+		t.Errorf("Query = %+v, want %+v", got, want)
+	}
+
+	cached, ok := c.readCache(65001)
+	if !ok {
+		t.Fatal("readCache found no entry after a fresh fetch")
+	}
+	if cached.ETag != `"fresh-etag"` {
+		t.Errorf("cached ETag = %q, want %q", cached.ETag, `"fresh-etag"`)
+	}
+}
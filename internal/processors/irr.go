@@ -0,0 +1,79 @@
+package processors
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/natesales/bcg/internal/config"
+)
+
+// IRRProcessor generates IPv4/IPv6 prefix filters for a peer's AS-SET by
+// invoking bgpq4 against the configured IRR database.
+type IRRProcessor struct{}
+
+func (p *IRRProcessor) Name() string { return "irr" }
+
+func (p *IRRProcessor) Enabled(global *config.Global) bool {
+	return !global.Processors.DisableIRR
+}
+
+func (p *IRRProcessor) Process(ctx context.Context, peerName string, peerData *config.Peer, global *config.Global) error {
+	if peerData.Type != "peer" && peerData.Type != "downstream" {
+		return nil
+	}
+
+	if peerData.AsSet == "" {
+		return fmt.Errorf("[%s] has no AS-SET defined and filtering profile requires it", peerName)
+	}
+
+	prefixes4, err := bgpq4(ctx, peerData.AsSet, 4, global.IrrDb)
+	if err != nil {
+		return fmt.Errorf("IPv4 prefix filter: %v", err)
+	}
+	peerData.PrefixSet4 = prefixes4
+
+	prefixes6, err := bgpq4(ctx, peerData.AsSet, 6, global.IrrDb)
+	if err != nil {
+		return fmt.Errorf("IPv6 prefix filter: %v", err)
+	}
+	peerData.PrefixSet6 = prefixes6
+
+	return nil
+}
+
+// bgpq4 runs bgpq4 for BIRD format with aggregation enabled and returns only
+// the filter lines.
+func bgpq4(ctx context.Context, asSet string, family uint8, irrdb string) ([]string, error) {
+	log.Infof("Running bgpq4 -h %s -Ab%d %s", irrdb, family, asSet)
+	cmd := exec.CommandContext(ctx, "bgpq4", "-h", irrdb, "-Ab"+strconv.Itoa(int(family)), asSet)
+	stdout, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("bgpq4 error: %v", err)
+	}
+
+	// Remove whitespace and commas from output
+	output := strings.ReplaceAll(string(stdout), ",\n    ", "\n")
+
+	// Remove array prefix
+	output = strings.ReplaceAll(output, "NN = [\n    ", "")
+
+	// Remove array suffix
+	output = strings.ReplaceAll(output, "];", "")
+
+	// Check for empty IRR
+	if output == "" {
+		log.Warnf("AS-SET %s has no IPv%d prefixes. Disabled IPv%d connectivity.", asSet, family, family)
+		return []string{}, nil
+	}
+
+	// Remove whitespace (in this case there should only be trailing whitespace)
+	output = strings.TrimSpace(output)
+
+	// Split output by newline
+	return strings.Split(output, "\n"), nil
+}
@@ -0,0 +1,92 @@
+package processors
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/natesales/bcg/internal/config"
+	"github.com/natesales/bcg/internal/peeringdb"
+)
+
+// PeeringDBProcessor looks up a peer's AS-SET and max-prefix limits from
+// PeeringDB when they aren't already set manually.
+type PeeringDBProcessor struct {
+	// APIKey is sent as an `Authorization: Api-Key ...` header, since
+	// PeeringDB heavily rate-limits anonymous queries.
+	APIKey string
+	// CacheDir, if set, enables an on-disk response cache keyed by ASN.
+	CacheDir string
+	// CacheTTL overrides peeringdb.DefaultTTL.
+	CacheTTL time.Duration
+
+	clientOnce sync.Once
+	client     *peeringdb.Client
+}
+
+func (p *PeeringDBProcessor) Name() string { return "peeringdb" }
+
+func (p *PeeringDBProcessor) Enabled(global *config.Global) bool {
+	return !global.Processors.DisablePeeringDB
+}
+
+func (p *PeeringDBProcessor) getClient() *peeringdb.Client {
+	p.clientOnce.Do(func() {
+		p.client = peeringdb.NewClient(p.APIKey, p.CacheDir, p.CacheTTL)
+	})
+	return p.client
+}
+
+func (p *PeeringDBProcessor) Process(ctx context.Context, peerName string, peerData *config.Peer, global *config.Global) error {
+	if peerData.Type != "peer" && peerData.Type != "downstream" {
+		return nil
+	}
+
+	peeringDbData, err := p.getClient().Query(peerData.Asn)
+	if err != nil {
+		return err
+	}
+
+	if peerData.ImportLimit4 == 0 {
+		peerData.ImportLimit4 = peeringDbData.MaxPfx4
+		log.Infof("[%s] has no IPv4 import limit configured. Setting to %d from PeeringDB", peerName, peeringDbData.MaxPfx4)
+	}
+
+	if peerData.ImportLimit6 == 0 {
+		peerData.ImportLimit6 = peeringDbData.MaxPfx6
+		log.Infof("[%s] has no IPv6 import limit configured. Setting to %d from PeeringDB", peerName, peeringDbData.MaxPfx6)
+	}
+
+	// Only set AS-SET from PeeringDB if it isn't configured manually
+	if peerData.AsSet != "" {
+		log.Infof("[%s] has manual AS-SET: %s", peerName, peerData.AsSet)
+		return nil
+	}
+
+	asSet := peeringDbData.AsSet
+
+	// If the as-set has a space in it, split and pick the first element
+	if strings.Contains(asSet, " ") {
+		asSet = strings.Split(asSet, " ")[0]
+		log.Warnf("[%s] has a space in their PeeringDB as-set field. Selecting first element %s", peerName, asSet)
+	}
+
+	// Trim IRRDB prefix
+	if strings.Contains(asSet, "::") {
+		asSet = strings.Split(asSet, "::")[1]
+		log.Warnf("[%s] has a IRRDB prefix in their PeeringDB as-set field. Using %s", peerName, asSet)
+	}
+
+	if asSet == "" {
+		return fmt.Errorf("[%s] has no as-set in PeeringDB", peerName)
+	}
+
+	peerData.AsSet = asSet
+	log.Infof("[%s] has no manual AS-SET defined. Setting to %s from PeeringDB", peerName, asSet)
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package processors
+
+import (
+	"context"
+	"sync"
+
+	"github.com/natesales/bcg/internal/config"
+)
+
+// ForEachPeer calls fn for every peer in global.Peers, fanning out across a
+// bounded worker pool of the given size so a config with many peers doesn't
+// pay each peer's PeeringDB/IRR round-trip latency serially. It blocks until
+// every peer has been processed.
+func ForEachPeer(concurrency int, global *config.Global, fn func(peerName string, peerData *config.Peer)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	type job struct {
+		name string
+		peer *config.Peer
+	}
+
+	jobs := make(chan job)
+	var wg sync.WaitGroup
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				fn(j.name, j.peer)
+			}
+		}()
+	}
+
+	for peerName, peerData := range global.Peers {
+		jobs <- job{name: peerName, peer: peerData}
+	}
+	close(jobs)
+
+	wg.Wait()
+}
+
+// RunAll fans the pipeline out across every peer in global.Peers using a
+// bounded worker pool of the given size.
+func (p *Pipeline) RunAll(ctx context.Context, global *config.Global, concurrency int) {
+	ForEachPeer(concurrency, global, func(peerName string, peerData *config.Peer) {
+		p.Run(ctx, peerName, peerData, global)
+	})
+}
@@ -0,0 +1,62 @@
+// Package processors decomposes per-peer resolution (PeeringDB lookups, IRR
+// prefix filters, RPKI validation, secret generation, and template rendering)
+// into small, independently toggleable stages that run as an ordered
+// pipeline. This replaces the inline peer-handling loop that used to live in
+// main.go.
+package processors
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/natesales/bcg/internal/config"
+)
+
+// Processor is a single stage of peer resolution. Implementations should be
+// safe to call concurrently across different peers.
+type Processor interface {
+	// Name identifies the processor in logs and peer status.
+	Name() string
+
+	// Enabled reports whether this processor should run for the given
+	// global config. Processors are skipped (not just no-op'd) when this
+	// returns false so disabled stages don't show up in peer status.
+	Enabled(global *config.Global) bool
+
+	// Process runs the stage against a single peer, mutating peerData in
+	// place. A returned error is recorded against the peer but does not
+	// stop the rest of the pipeline from running.
+	Process(ctx context.Context, peerName string, peerData *config.Peer, global *config.Global) error
+}
+
+// Pipeline is an ordered list of processors run for every peer.
+type Pipeline struct {
+	stages []Processor
+}
+
+// NewPipeline builds a pipeline from the given processors, run in the order
+// provided.
+func NewPipeline(stages ...Processor) *Pipeline {
+	return &Pipeline{stages: stages}
+}
+
+// Run executes every enabled stage in order against a single peer. Per-stage
+// errors are attached to peerData.Status and logged, but do not abort later
+// stages; Run never stops early.
+func (p *Pipeline) Run(ctx context.Context, peerName string, peerData *config.Peer, global *config.Global) {
+	for _, stage := range p.stages {
+		if !stage.Enabled(global) {
+			continue
+		}
+
+		log.Debugf("[%s] running processor %s", peerName, stage.Name())
+		if err := stage.Process(ctx, peerName, peerData, global); err != nil {
+			log.Errorf("[%s] processor %s: %v", peerName, stage.Name(), err)
+			peerData.SetProcessorStatus(stage.Name(), err)
+			continue
+		}
+
+		peerData.SetProcessorStatus(stage.Name(), nil)
+	}
+}
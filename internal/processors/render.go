@@ -0,0 +1,83 @@
+package processors
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/kennygrant/sanitize"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/natesales/bcg/internal/config"
+	"github.com/natesales/bcg/internal/templating"
+)
+
+// RenderProcessor renders the per-peer BIRD template to disk. It runs last
+// in the pipeline so every other processor has had a chance to populate
+// peerData first.
+type RenderProcessor struct {
+	OutputDir string
+	DryRun    bool
+}
+
+func (p *RenderProcessor) Name() string { return "render" }
+
+func (p *RenderProcessor) Enabled(global *config.Global) bool { return true }
+
+func (p *RenderProcessor) Process(ctx context.Context, peerName string, peerData *config.Peer, global *config.Global) error {
+	// Add peer prefix if the first character of peerName is a number
+	normalizedName := strings.ReplaceAll(normalize(peerName), "-", "_")
+	if unicode.IsDigit(rune(normalizedName[0])) {
+		normalizedName = "PEER_" + normalizedName
+	}
+	peerData.Name = normalizedName
+
+	// A failed PeeringDB or IRR lookup leaves this peer without the
+	// import filters its type requires; don't write a config for it
+	// rather than push a session with broken or empty filtering.
+	for _, stage := range []string{"peeringdb", "irr"} {
+		if status, ok := peerData.Status[stage]; ok && status.Err != nil {
+			return fmt.Errorf("not rendering config: %s processor failed: %w", stage, status.Err)
+		}
+	}
+
+	if p.DryRun {
+		log.Infof("Dry run is enabled, skipped writing config for %s", peerName)
+		return nil
+	}
+
+	peerSpecificFile, err := os.Create(path.Join(p.OutputDir, "AS"+strconv.Itoa(int(peerData.Asn))+"_"+normalize(peerName)+".conf"))
+	if err != nil {
+		return err
+	}
+	defer peerSpecificFile.Close() //nolint:errcheck
+
+	log.Infof("[%s] Writing config", peerName)
+	if err := templating.PeerTemplate.ExecuteTemplate(peerSpecificFile, "peer.tmpl", &config.Wrapper{Peer: *peerData, Config: *global}); err != nil {
+		return err
+	}
+	log.Infof("[%s] Wrote config", peerName)
+
+	return nil
+}
+
+// normalize a string to be filename-safe
+func normalize(input string) string {
+	// Remove non-alphanumeric characters
+	input = sanitize.Path(input)
+
+	// Make uppercase
+	input = strings.ToUpper(input)
+
+	// Replace spaces with underscores
+	input = strings.ReplaceAll(input, " ", "_")
+
+	// Replace slashes with dashes
+	input = strings.ReplaceAll(input, "/", "-")
+
+	return input
+}
@@ -0,0 +1,67 @@
+package processors
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/natesales/bcg/internal/config"
+	"github.com/natesales/bcg/internal/rpki"
+)
+
+// RPKIProcessor cross-checks a peer's IRR-derived prefix list against RPKI
+// ROA data pulled over RTR, dropping prefixes that would be ROA_INVALID for
+// the peer's ASN.
+type RPKIProcessor struct{}
+
+func (p *RPKIProcessor) Name() string { return "rpki" }
+
+func (p *RPKIProcessor) Enabled(global *config.Global) bool {
+	return global.RPKI.Host != ""
+}
+
+func (p *RPKIProcessor) Process(ctx context.Context, peerName string, peerData *config.Peer, global *config.Global) error {
+	if peerData.Type != "peer" && peerData.Type != "downstream" {
+		return nil
+	}
+
+	client, err := rpki.Shared(global.RPKI.Host, global.RPKI.Port, global.RPKI.RefreshInterval)
+	if err != nil {
+		return err
+	}
+
+	peerData.PrefixSet4 = filterInvalid(client, peerName, peerData.Asn, peerData.PrefixSet4)
+	peerData.PrefixSet6 = filterInvalid(client, peerName, peerData.Asn, peerData.PrefixSet6)
+
+	return nil
+}
+
+// filterInvalid drops prefix lines that validate as ROA_INVALID against the
+// peer's ASN. Lines that can't be parsed as a bare CIDR prefix (e.g. bgpq4
+// comments) are passed through unchanged.
+func filterInvalid(client *rpki.Client, peerName string, asn uint, lines []string) []string {
+	filtered := make([]string, 0, len(lines))
+
+	for _, line := range lines {
+		prefix := strings.TrimSpace(strings.SplitN(line, " ", 2)[0])
+		prefix = strings.TrimSuffix(prefix, ",")
+		prefix = strings.TrimSuffix(prefix, ";")
+
+		_, ipNet, err := net.ParseCIDR(prefix)
+		if err != nil {
+			filtered = append(filtered, line)
+			continue
+		}
+
+		if client.Validate(ipNet, uint32(asn)) == rpki.Invalid {
+			log.Warnf("[%s] dropping %s: ROA_INVALID for AS%d", peerName, prefix, asn)
+			continue
+		}
+
+		filtered = append(filtered, line)
+	}
+
+	return filtered
+}
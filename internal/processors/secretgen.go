@@ -0,0 +1,53 @@
+package processors
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/natesales/bcg/internal/config"
+	"github.com/natesales/bcg/internal/secrets"
+)
+
+// DefaultSecretsFile is where generated session secrets are persisted when
+// no --secrets-file override is given.
+const DefaultSecretsFile = "/var/lib/bcg/secrets.json"
+
+// SecretGenProcessor generates and persists per-session BGP authentication
+// secrets for peers with generate_password set.
+type SecretGenProcessor struct {
+	// SecretsFile overrides DefaultSecretsFile.
+	SecretsFile string
+}
+
+func (p *SecretGenProcessor) Name() string { return "secretgen" }
+
+func (p *SecretGenProcessor) Enabled(global *config.Global) bool { return true }
+
+func (p *SecretGenProcessor) Process(ctx context.Context, peerName string, peerData *config.Peer, global *config.Global) error {
+	if !peerData.GeneratePassword && !global.GeneratePassword {
+		return nil
+	}
+
+	path := p.SecretsFile
+	if path == "" {
+		path = DefaultSecretsFile
+	}
+
+	store, err := secrets.Shared(path)
+	if err != nil {
+		return fmt.Errorf("open secrets store: %w", err)
+	}
+
+	for _, neighbor := range peerData.NeighborIPs {
+		secret, err := store.GetOrCreate(peerData.Asn, neighbor)
+		if err != nil {
+			return fmt.Errorf("generate secret for %s: %w", neighbor, err)
+		}
+		peerData.Passwords = append(peerData.Passwords, secret.Secret)
+		log.Debugf("[%s] session secret ready for %s", peerName, neighbor)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package rpki
+
+import (
+	"fmt"
+	"io"
+)
+
+// WriteTables renders BIRD roa4/roa6 tables, populated via a static
+// protocol, plus roa_check_v4()/roa_check_v6() filter functions from the
+// given ROA set. Each function returns ROA_VALID/ROA_INVALID/ROA_UNKNOWN for
+// the current net and the given peer_asn so peer filters can reject invalids
+// on import.
+func WriteTables(w io.Writer, roas []ROA) error {
+	var roas4, roas6 []ROA
+	for _, roa := range roas {
+		if roa.Prefix.IP.To4() == nil {
+			roas6 = append(roas6, roa)
+		} else {
+			roas4 = append(roas4, roa)
+		}
+	}
+
+	fmt.Fprintln(w, "# Generated by bcg RPKI processor. Do not edit.")
+	fmt.Fprintln(w, "roa4 table bcg_roa4;")
+	fmt.Fprintln(w, "roa6 table bcg_roa6;")
+	fmt.Fprintln(w)
+
+	if err := writeStaticRoaProtocol(w, "bcg_roa4_feed", "roa4", "bcg_roa4", roas4); err != nil {
+		return err
+	}
+	if err := writeStaticRoaProtocol(w, "bcg_roa6_feed", "roa6", "bcg_roa6", roas6); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "function roa_check_v4(int peer_asn) {")
+	fmt.Fprintln(w, "\tif roa_check(bcg_roa4, net, peer_asn) = ROA_VALID then return ROA_VALID;")
+	fmt.Fprintln(w, "\tif roa_check(bcg_roa4, net, peer_asn) = ROA_INVALID then return ROA_INVALID;")
+	fmt.Fprintln(w, "\treturn ROA_UNKNOWN;")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "function roa_check_v6(int peer_asn) {")
+	fmt.Fprintln(w, "\tif roa_check(bcg_roa6, net, peer_asn) = ROA_VALID then return ROA_VALID;")
+	fmt.Fprintln(w, "\tif roa_check(bcg_roa6, net, peer_asn) = ROA_INVALID then return ROA_INVALID;")
+	fmt.Fprintln(w, "\treturn ROA_UNKNOWN;")
+	fmt.Fprintln(w, "}")
+
+	return nil
+}
+
+// writeStaticRoaProtocol emits a `protocol static { roaN { table ...; };
+// route ... max ... as ...; ... }` block feeding one roa table, which is
+// how BIRD 2.x populates roa4/roa6 tables from static data (there is no
+// standalone `roa ... in TABLE;` directive).
+func writeStaticRoaProtocol(w io.Writer, protoName, family, table string, roas []ROA) error {
+	fmt.Fprintf(w, "protocol static %s {\n", protoName)
+	fmt.Fprintf(w, "\t%s { table %s; };\n", family, table)
+	for _, roa := range roas {
+		if _, err := fmt.Fprintf(w, "\troute %s max %d as %d;\n", roa.Prefix.String(), roa.MaxLen, roa.ASN); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w)
+	return nil
+}
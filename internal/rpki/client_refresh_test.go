@@ -0,0 +1,143 @@
+package rpki
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fakeRTRServer is a minimal RTR cache: every Reset Query gets the full
+// table (one ROA for AS65001, serial 1); every Serial Query is answered by
+// onSerialQuery, or with a Cache Reset if it's nil.
+type fakeRTRServer struct {
+	ln             net.Listener
+	sessID         uint16
+	onSerialQuery  func(conn net.Conn)
+	resetResponses int
+}
+
+func startFakeRTRServer(t *testing.T, sessID uint16) *fakeRTRServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &fakeRTRServer{ln: ln, sessID: sessID}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *fakeRTRServer) handle(conn net.Conn) {
+	defer conn.Close() //nolint:errcheck
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return
+	}
+	length := binary.BigEndian.Uint32(header[4:8])
+	if length > 8 {
+		body := make([]byte, length-8)
+		_, _ = io.ReadFull(conn, body)
+	}
+
+	switch header[1] {
+	case pduResetQuery:
+		s.resetResponses++
+		conn.Write(buildPDU(pduCacheResponse, s.sessID, nil))
+		conn.Write(buildPDU(pduIPv4Prefix, s.sessID, ipv4PrefixBody(true, 24, 24, [4]byte{203, 0, 113, 0}, 65001)))
+		serialBody := make([]byte, 4)
+		binary.BigEndian.PutUint32(serialBody, 1)
+		conn.Write(buildPDU(pduEndOfData, s.sessID, serialBody))
+	case pduSerialQuery:
+		if s.onSerialQuery != nil {
+			s.onSerialQuery(conn)
+		} else {
+			conn.Write(buildPDU(pduCacheReset, s.sessID, nil))
+		}
+	}
+}
+
+func (s *fakeRTRServer) hostPort(t *testing.T) (string, uint16) {
+	t.Helper()
+	_, portStr, err := net.SplitHostPort(s.ln.Addr().String())
+	if err != nil {
+		t.Fatalf("split host port: %v", err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatalf("parse port: %v", err)
+	}
+	return "127.0.0.1", uint16(port)
+}
+
+func TestClientRefreshUsesSerialQueryOnceSessionEstablished(t *testing.T) {
+	server := startFakeRTRServer(t, 42)
+	server.onSerialQuery = func(conn net.Conn) {
+		conn.Write(buildPDU(pduCacheResponse, server.sessID, nil))
+		conn.Write(buildPDU(pduIPv4Prefix, server.sessID, ipv4PrefixBody(true, 24, 24, [4]byte{198, 51, 100, 0}, 65002)))
+		serialBody := make([]byte, 4)
+		binary.BigEndian.PutUint32(serialBody, 2)
+		conn.Write(buildPDU(pduEndOfData, server.sessID, serialBody))
+	}
+
+	host, port := server.hostPort(t)
+	c := NewClient(host, port, time.Minute)
+
+	if err := c.refresh(); err != nil {
+		t.Fatalf("first refresh (reset query): %v", err)
+	}
+	if len(c.ROAs()) != 1 {
+		t.Fatalf("after reset query, ROAs = %d, want 1", len(c.ROAs()))
+	}
+
+	if err := c.refresh(); err != nil {
+		t.Fatalf("second refresh (serial query): %v", err)
+	}
+	roas := c.ROAs()
+	if len(roas) != 2 {
+		t.Fatalf("after serial query, ROAs = %d, want 2 (merged): %+v", len(roas), roas)
+	}
+
+	var asns []uint32
+	for _, roa := range roas {
+		asns = append(asns, roa.ASN)
+	}
+	if !containsASN(asns, 65001) || !containsASN(asns, 65002) {
+		t.Errorf("ROA ASNs = %v, want both 65001 (from reset) and 65002 (from serial)", asns)
+	}
+}
+
+func TestClientRefreshFallsBackToResetQueryOnCacheReset(t *testing.T) {
+	server := startFakeRTRServer(t, 42) // onSerialQuery left nil -> always Cache Reset
+
+	host, port := server.hostPort(t)
+	c := NewClient(host, port, time.Minute)
+
+	if err := c.refresh(); err != nil {
+		t.Fatalf("first refresh (reset query): %v", err)
+	}
+	if err := c.refresh(); err != nil {
+		t.Fatalf("second refresh (serial query falling back to reset): %v", err)
+	}
+
+	if server.resetResponses != 2 {
+		t.Errorf("server saw %d Reset Query requests, want 2 (the fallback should have sent a second one)", server.resetResponses)
+	}
+	if len(c.ROAs()) != 1 {
+		t.Fatalf("ROAs = %d, want 1 from the fallback reset query", len(c.ROAs()))
+	}
+}
@@ -0,0 +1,34 @@
+package rpki
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	clientsMu sync.Mutex
+	clients   = map[string]*Client{}
+)
+
+// Shared returns the process-wide RTR client for the given cache server,
+// starting it on first use. Peer processors call this instead of each
+// opening their own RTR session.
+func Shared(host string, port uint16, refreshInterval time.Duration) (*Client, error) {
+	key := fmt.Sprintf("%s:%d", host, port)
+
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	if c, ok := clients[key]; ok {
+		return c, nil
+	}
+
+	c := NewClient(host, port, refreshInterval)
+	if err := c.Start(); err != nil {
+		return nil, err
+	}
+	clients[key] = c
+
+	return c, nil
+}
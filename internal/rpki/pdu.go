@@ -0,0 +1,173 @@
+package rpki
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+)
+
+// maxPDULength bounds how much a single PDU's declared length can ask us to
+// allocate. RTR PDUs are small (an IPv6 Prefix PDU is 32 bytes); this
+// is a generous ceiling against a buggy or malicious cache, not a protocol
+// limit.
+const maxPDULength = 64 * 1024
+
+// readPDU reads one PDU's 8-byte header plus its length-validated body.
+func readPDU(conn net.Conn) (pduType byte, sessID uint16, body []byte, err error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return 0, 0, nil, err
+	}
+
+	pduType = header[1]
+	sessID = binary.BigEndian.Uint16(header[2:4])
+	length := binary.BigEndian.Uint32(header[4:8])
+
+	if length < 8 || length > maxPDULength {
+		return 0, 0, nil, fmt.Errorf("invalid PDU length %d", length)
+	}
+
+	body = make([]byte, length-8)
+	if len(body) > 0 {
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return 0, 0, nil, err
+		}
+	}
+
+	return pduType, sessID, body, nil
+}
+
+// readPDUs reads a Reset Query response until End of Data, returning the
+// full ROA table along with the serial number and session ID the cache
+// reported.
+func readPDUs(conn net.Conn) ([]ROA, uint32, uint16, error) {
+	var roas []ROA
+	var serial uint32
+	var sessID uint16
+
+	for {
+		pduType, gotSessID, body, err := readPDU(conn)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		sessID = gotSessID
+
+		switch pduType {
+		case pduCacheResponse:
+			// Nothing to extract; prefix PDUs follow.
+		case pduIPv4Prefix:
+			roa, _, err := parseIPv4PrefixPDU(body)
+			if err != nil {
+				return nil, 0, 0, err
+			}
+			roas = append(roas, roa)
+		case pduIPv6Prefix:
+			roa, _, err := parseIPv6PrefixPDU(body)
+			if err != nil {
+				return nil, 0, 0, err
+			}
+			roas = append(roas, roa)
+		case pduEndOfData:
+			if len(body) >= 4 {
+				serial = binary.BigEndian.Uint32(body[0:4])
+			}
+			return roas, serial, sessID, nil
+		case pduCacheReset:
+			// Cache wants a full Reset Query; caller will retry on the
+			// next refresh tick.
+			return nil, 0, 0, fmt.Errorf("cache sent Cache Reset, retry required")
+		default:
+			return nil, 0, 0, fmt.Errorf("unexpected PDU type %d", pduType)
+		}
+	}
+}
+
+// readIncrementalPDUs reads a Serial Query response: zero or more announced
+// or withdrawn prefix PDUs followed by End of Data carrying the cache's new
+// serial number. cacheReset is true (with roas all nil) if the cache
+// replied with Cache Reset instead, meaning it couldn't serve the
+// requested serial and the caller must fall back to a Reset Query.
+func readIncrementalPDUs(conn net.Conn) (announced, withdrawn []ROA, serial uint32, sessID uint16, cacheReset bool, err error) {
+	for {
+		pduType, gotSessID, body, err := readPDU(conn)
+		if err != nil {
+			return nil, nil, 0, 0, false, err
+		}
+		sessID = gotSessID
+
+		switch pduType {
+		case pduCacheResponse:
+			// Nothing to extract; prefix PDUs follow.
+		case pduIPv4Prefix:
+			roa, announce, err := parseIPv4PrefixPDU(body)
+			if err != nil {
+				return nil, nil, 0, 0, false, err
+			}
+			if announce {
+				announced = append(announced, roa)
+			} else {
+				withdrawn = append(withdrawn, roa)
+			}
+		case pduIPv6Prefix:
+			roa, announce, err := parseIPv6PrefixPDU(body)
+			if err != nil {
+				return nil, nil, 0, 0, false, err
+			}
+			if announce {
+				announced = append(announced, roa)
+			} else {
+				withdrawn = append(withdrawn, roa)
+			}
+		case pduEndOfData:
+			if len(body) >= 4 {
+				serial = binary.BigEndian.Uint32(body[0:4])
+			}
+			return announced, withdrawn, serial, sessID, false, nil
+		case pduCacheReset:
+			return nil, nil, 0, 0, true, nil
+		default:
+			return nil, nil, 0, 0, false, fmt.Errorf("unexpected PDU type %d", pduType)
+		}
+	}
+}
+
+// parseIPv4PrefixPDU parses an IPv4 Prefix PDU body (RFC 8210 section 5.6).
+// announce reports the PDU's Flags bit: true for an announcement, false for
+// a withdrawal (only meaningful for Serial Query responses; Reset Query
+// responses are always announcements).
+func parseIPv4PrefixPDU(body []byte) (roa ROA, announce bool, err error) {
+	if len(body) < 12 {
+		return ROA{}, false, fmt.Errorf("short IPv4 prefix PDU")
+	}
+
+	prefixLen := body[1]
+	maxLen := body[2]
+	addr := net.IPv4(body[4], body[5], body[6], body[7])
+	asn := binary.BigEndian.Uint32(body[8:12])
+
+	return ROA{
+		Prefix: &net.IPNet{IP: addr, Mask: net.CIDRMask(int(prefixLen), 32)},
+		MaxLen: maxLen,
+		ASN:    asn,
+	}, body[0]&1 == 1, nil
+}
+
+// parseIPv6PrefixPDU parses an IPv6 Prefix PDU body (RFC 8210 section 5.8).
+// See parseIPv4PrefixPDU for the meaning of announce.
+func parseIPv6PrefixPDU(body []byte) (roa ROA, announce bool, err error) {
+	if len(body) < 24 {
+		return ROA{}, false, fmt.Errorf("short IPv6 prefix PDU")
+	}
+
+	prefixLen := body[1]
+	maxLen := body[2]
+	addr := net.IP(body[4:20])
+	asn := binary.BigEndian.Uint32(body[20:24])
+
+	return ROA{
+		Prefix: &net.IPNet{IP: addr, Mask: net.CIDRMask(int(prefixLen), 128)},
+		MaxLen: maxLen,
+		ASN:    asn,
+	}, body[0]&1 == 1, nil
+}
@@ -0,0 +1,250 @@
+package rpki
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// buildPDU assembles a PDU header (version 0) followed by body.
+func buildPDU(pduType byte, sessID uint16, body []byte) []byte {
+	pdu := make([]byte, 8+len(body))
+	pdu[0] = 0 // protocol version
+	pdu[1] = pduType
+	binary.BigEndian.PutUint16(pdu[2:4], sessID)
+	binary.BigEndian.PutUint32(pdu[4:8], uint32(8+len(body)))
+	copy(pdu[8:], body)
+	return pdu
+}
+
+func ipv4PrefixBody(announce bool, prefixLen, maxLen uint8, addr [4]byte, asn uint32) []byte {
+	body := make([]byte, 12)
+	if announce {
+		body[0] = 1
+	}
+	body[1] = prefixLen
+	body[2] = maxLen
+	copy(body[4:8], addr[:])
+	binary.BigEndian.PutUint32(body[8:12], asn)
+	return body
+}
+
+func TestReadPDUs(t *testing.T) {
+	sessID := uint16(42)
+	var wire []byte
+	wire = append(wire, buildPDU(pduCacheResponse, sessID, nil)...)
+	wire = append(wire, buildPDU(pduIPv4Prefix, sessID, ipv4PrefixBody(true, 24, 24, [4]byte{203, 0, 113, 0}, 65001))...)
+
+	serialBody := make([]byte, 4)
+	binary.BigEndian.PutUint32(serialBody, 7)
+	wire = append(wire, buildPDU(pduEndOfData, sessID, serialBody)...)
+
+	server, client := net.Pipe()
+	defer server.Close() //nolint:errcheck
+	go func() {
+		_, _ = server.Write(wire)
+	}()
+
+	roas, serial, gotSessID, err := readPDUs(client)
+	if err != nil {
+		t.Fatalf("readPDUs: %v", err)
+	}
+	if serial != 7 {
+		t.Errorf("serial = %d, want 7", serial)
+	}
+	if gotSessID != sessID {
+		t.Errorf("sessID = %d, want %d", gotSessID, sessID)
+	}
+	if len(roas) != 1 {
+		t.Fatalf("got %d ROAs, want 1", len(roas))
+	}
+	if roas[0].ASN != 65001 || roas[0].MaxLen != 24 {
+		t.Errorf("roa = %+v, want ASN 65001 MaxLen 24", roas[0])
+	}
+	if roas[0].Prefix.String() != "203.0.113.0/24" {
+		t.Errorf("prefix = %s, want 203.0.113.0/24", roas[0].Prefix.String())
+	}
+}
+
+func TestReadPDUsCacheReset(t *testing.T) {
+	wire := buildPDU(pduCacheReset, 0, nil)
+
+	server, client := net.Pipe()
+	defer server.Close() //nolint:errcheck
+	go func() {
+		_, _ = server.Write(wire)
+	}()
+
+	if _, _, _, err := readPDUs(client); err == nil {
+		t.Fatal("expected error on Cache Reset, got nil")
+	}
+}
+
+func TestReadPDUsRejectsOversizedLength(t *testing.T) {
+	// A declared length larger than the cap must be rejected before we
+	// attempt to allocate or read a body of that size.
+	header := make([]byte, 8)
+	header[1] = pduIPv4Prefix
+	binary.BigEndian.PutUint32(header[4:8], maxPDULength+1)
+
+	server, client := net.Pipe()
+	defer server.Close() //nolint:errcheck
+	go func() {
+		_, _ = server.Write(header)
+	}()
+
+	if _, _, _, err := readPDUs(client); err == nil {
+		t.Fatal("expected error for oversized PDU length, got nil")
+	}
+}
+
+func TestReadPDUsRejectsUndersizedLength(t *testing.T) {
+	// A declared length smaller than the 8-byte header would underflow
+	// length-8 if read as an unsigned allocation size; make sure it's
+	// rejected instead of panicking.
+	header := make([]byte, 8)
+	header[1] = pduIPv4Prefix
+	binary.BigEndian.PutUint32(header[4:8], 3)
+
+	server, client := net.Pipe()
+	defer server.Close() //nolint:errcheck
+	go func() {
+		_, _ = server.Write(header)
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, _, _, err := readPDUs(client); err == nil {
+			t.Error("expected error for undersized PDU length, got nil")
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("readPDUs did not return for an undersized length")
+	}
+}
+
+func TestParseIPv4PrefixPDUShortBody(t *testing.T) {
+	if _, _, err := parseIPv4PrefixPDU(make([]byte, 4)); err == nil {
+		t.Fatal("expected error for short IPv4 prefix body, got nil")
+	}
+}
+
+func TestParseIPv6PrefixPDUShortBody(t *testing.T) {
+	if _, _, err := parseIPv6PrefixPDU(make([]byte, 4)); err == nil {
+		t.Fatal("expected error for short IPv6 prefix body, got nil")
+	}
+}
+
+func TestParseIPv4PrefixPDUFlag(t *testing.T) {
+	_, announce, err := parseIPv4PrefixPDU(ipv4PrefixBody(true, 24, 24, [4]byte{203, 0, 113, 0}, 65001))
+	if err != nil {
+		t.Fatalf("parseIPv4PrefixPDU: %v", err)
+	}
+	if !announce {
+		t.Error("announce = false, want true for an announcement PDU")
+	}
+
+	_, announce, err = parseIPv4PrefixPDU(ipv4PrefixBody(false, 24, 24, [4]byte{203, 0, 113, 0}, 65001))
+	if err != nil {
+		t.Fatalf("parseIPv4PrefixPDU: %v", err)
+	}
+	if announce {
+		t.Error("announce = true, want false for a withdrawal PDU")
+	}
+}
+
+func TestReadIncrementalPDUs(t *testing.T) {
+	sessID := uint16(42)
+	var wire []byte
+	wire = append(wire, buildPDU(pduCacheResponse, sessID, nil)...)
+	wire = append(wire, buildPDU(pduIPv4Prefix, sessID, ipv4PrefixBody(true, 24, 24, [4]byte{203, 0, 113, 0}, 65001))...)
+	wire = append(wire, buildPDU(pduIPv4Prefix, sessID, ipv4PrefixBody(false, 24, 24, [4]byte{198, 51, 100, 0}, 65002))...)
+
+	serialBody := make([]byte, 4)
+	binary.BigEndian.PutUint32(serialBody, 8)
+	wire = append(wire, buildPDU(pduEndOfData, sessID, serialBody)...)
+
+	server, client := net.Pipe()
+	defer server.Close() //nolint:errcheck
+	go func() {
+		_, _ = server.Write(wire)
+	}()
+
+	announced, withdrawn, serial, gotSessID, cacheReset, err := readIncrementalPDUs(client)
+	if err != nil {
+		t.Fatalf("readIncrementalPDUs: %v", err)
+	}
+	if cacheReset {
+		t.Fatal("cacheReset = true, want false")
+	}
+	if serial != 8 || gotSessID != sessID {
+		t.Errorf("serial/sessID = %d/%d, want 8/%d", serial, gotSessID, sessID)
+	}
+	if len(announced) != 1 || announced[0].ASN != 65001 {
+		t.Errorf("announced = %+v, want one ROA for AS65001", announced)
+	}
+	if len(withdrawn) != 1 || withdrawn[0].ASN != 65002 {
+		t.Errorf("withdrawn = %+v, want one ROA for AS65002", withdrawn)
+	}
+}
+
+func TestReadIncrementalPDUsCacheReset(t *testing.T) {
+	wire := buildPDU(pduCacheReset, 0, nil)
+
+	server, client := net.Pipe()
+	defer server.Close() //nolint:errcheck
+	go func() {
+		_, _ = server.Write(wire)
+	}()
+
+	_, _, _, _, cacheReset, err := readIncrementalPDUs(client)
+	if err != nil {
+		t.Fatalf("readIncrementalPDUs: %v", err)
+	}
+	if !cacheReset {
+		t.Error("cacheReset = false, want true")
+	}
+}
+
+func TestApplyDelta(t *testing.T) {
+	base := []ROA{
+		{Prefix: mustParseCIDR(t, "203.0.113.0/24"), MaxLen: 24, ASN: 65001},
+		{Prefix: mustParseCIDR(t, "198.51.100.0/24"), MaxLen: 24, ASN: 65002},
+	}
+	announced := []ROA{
+		{Prefix: mustParseCIDR(t, "192.0.2.0/24"), MaxLen: 24, ASN: 65003},
+	}
+	withdrawn := []ROA{
+		{Prefix: mustParseCIDR(t, "198.51.100.0/24"), MaxLen: 24, ASN: 65002},
+	}
+
+	merged := applyDelta(base, announced, withdrawn)
+
+	var asns []uint32
+	for _, roa := range merged {
+		asns = append(asns, roa.ASN)
+	}
+	if len(merged) != 2 {
+		t.Fatalf("merged = %+v, want 2 ROAs", merged)
+	}
+	if !containsASN(asns, 65001) || !containsASN(asns, 65003) {
+		t.Errorf("merged ASNs = %v, want 65001 and 65003", asns)
+	}
+	if containsASN(asns, 65002) {
+		t.Errorf("merged ASNs = %v, want 65002 withdrawn", asns)
+	}
+}
+
+func containsASN(asns []uint32, asn uint32) bool {
+	for _, a := range asns {
+		if a == asn {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,302 @@
+// Package rpki implements a minimal RTR (RFC 8210) client for pulling ROA
+// data from an RPKI validator cache, plus ROA_VALID/ROA_INVALID/ROA_UNKNOWN
+// route validation against that data.
+package rpki
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PDU types used by this client (RFC 8210 section 5).
+const (
+	pduSerialNotify  = 0
+	pduSerialQuery   = 1
+	pduResetQuery    = 2
+	pduCacheResponse = 3
+	pduIPv4Prefix    = 4
+	pduIPv6Prefix    = 6
+	pduEndOfData     = 7
+	pduCacheReset    = 8
+)
+
+// ROA is a single Route Origin Authorization entry.
+type ROA struct {
+	Prefix *net.IPNet
+	MaxLen uint8
+	ASN    uint32
+}
+
+// Validity is the outcome of validating a route against the ROA table.
+type Validity string
+
+const (
+	Valid   Validity = "ROA_VALID"
+	Invalid Validity = "ROA_INVALID"
+	Unknown Validity = "ROA_UNKNOWN"
+)
+
+// Client maintains a connection to an RTR cache server and keeps an
+// in-memory table of ROAs up to date via periodic refreshes.
+type Client struct {
+	Host            string
+	Port            uint16
+	RefreshInterval time.Duration
+
+	mu          sync.RWMutex
+	roas        []ROA
+	serial      uint32
+	sessID      uint16
+	haveSession bool
+	onUpdate    func([]ROA)
+
+	stop chan struct{}
+}
+
+// NewClient creates an RTR client for the given cache server.
+func NewClient(host string, port uint16, refreshInterval time.Duration) *Client {
+	if refreshInterval == 0 {
+		refreshInterval = time.Minute
+	}
+	return &Client{
+		Host:            host,
+		Port:            port,
+		RefreshInterval: refreshInterval,
+		stop:            make(chan struct{}),
+	}
+}
+
+// Start performs an initial Reset Query and then refreshes the ROA table on
+// a ticker until Stop is called.
+func (c *Client) Start() error {
+	if err := c.refresh(); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(c.RefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := c.refresh(); err != nil {
+					log.Errorf("rpki: refresh %s:%d: %v", c.Host, c.Port, err)
+				}
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop halts the background refresh loop.
+func (c *Client) Stop() {
+	close(c.stop)
+}
+
+// SetOnUpdate sets the callback invoked with the new ROA table after every
+// successful refresh, so callers can re-render the BIRD roa tables. It may
+// be called concurrently with a background refresh.
+func (c *Client) SetOnUpdate(fn func([]ROA)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onUpdate = fn
+}
+
+// ROAs returns a snapshot of the current ROA table.
+func (c *Client) ROAs() []ROA {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]ROA, len(c.roas))
+	copy(out, c.roas)
+	return out
+}
+
+// Validate checks a prefix/ASN pair against the current ROA table.
+func (c *Client) Validate(prefix *net.IPNet, asn uint32) Validity {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	prefixLen, _ := prefix.Mask.Size()
+	covered := false
+
+	for _, roa := range c.roas {
+		if !roa.Prefix.Contains(prefix.IP) {
+			continue
+		}
+		roaLen, _ := roa.Prefix.Mask.Size()
+		if prefixLen < roaLen {
+			continue
+		}
+		covered = true
+		if uint8(prefixLen) <= roa.MaxLen && roa.ASN == asn {
+			return Valid
+		}
+	}
+
+	if covered {
+		return Invalid
+	}
+	return Unknown
+}
+
+// refresh fetches the latest ROAs from the cache. Once a session has been
+// established with a prior Reset Query, it first tries an incremental
+// Serial Query (RFC 8210 section 5.3) so a large ROA table doesn't have to
+// be re-downloaded on every tick; if the cache can't serve that serial (it
+// replies with Cache Reset) or no session exists yet, it falls back to a
+// full Reset Query.
+func (c *Client) refresh() error {
+	c.mu.RLock()
+	haveSession := c.haveSession
+	sessID := c.sessID
+	serial := c.serial
+	c.mu.RUnlock()
+
+	if haveSession {
+		roas, newSerial, newSessID, ok, err := c.serialQuery(sessID, serial)
+		if err != nil {
+			return fmt.Errorf("serial query: %w", err)
+		}
+		if ok {
+			c.commit(roas, newSerial, newSessID)
+			return nil
+		}
+		log.Infof("rpki: %s:%d sent Cache Reset, falling back to a full refresh", c.Host, c.Port)
+	}
+
+	roas, serial, sessID, err := c.resetQuery()
+	if err != nil {
+		return fmt.Errorf("reset query: %w", err)
+	}
+	c.commit(roas, serial, sessID)
+	return nil
+}
+
+// commit replaces the in-memory ROA table, marks the session usable for
+// the next incremental refresh, and notifies onUpdate.
+func (c *Client) commit(roas []ROA, serial uint32, sessID uint16) {
+	c.mu.Lock()
+	c.roas = roas
+	c.serial = serial
+	c.sessID = sessID
+	c.haveSession = true
+	onUpdate := c.onUpdate
+	c.mu.Unlock()
+
+	log.Infof("rpki: refreshed %d ROAs from %s:%d (serial %d)", len(roas), c.Host, c.Port, serial)
+
+	if onUpdate != nil {
+		onUpdate(roas)
+	}
+}
+
+// resetQuery opens a new RTR session and requests the full ROA table.
+func (c *Client) resetQuery() ([]ROA, uint32, uint16, error) {
+	conn, err := c.dial()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer conn.Close() //nolint:errcheck
+
+	if err := sendResetQuery(conn); err != nil {
+		return nil, 0, 0, fmt.Errorf("send reset query: %w", err)
+	}
+
+	roas, serial, sessID, err := readPDUs(conn)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("read pdus: %w", err)
+	}
+	return roas, serial, sessID, nil
+}
+
+// serialQuery requests only the ROAs that changed since serial within
+// session sessID, and applies the resulting announcements/withdrawals to
+// the current table. ok is false (with a nil error) if the cache replied
+// with Cache Reset, meaning the caller must fall back to resetQuery.
+func (c *Client) serialQuery(sessID uint16, serial uint32) (roas []ROA, newSerial uint32, newSessID uint16, ok bool, err error) {
+	conn, err := c.dial()
+	if err != nil {
+		return nil, 0, 0, false, err
+	}
+	defer conn.Close() //nolint:errcheck
+
+	if err := sendSerialQuery(conn, sessID, serial); err != nil {
+		return nil, 0, 0, false, fmt.Errorf("send serial query: %w", err)
+	}
+
+	announced, withdrawn, newSerial, newSessID, cacheReset, err := readIncrementalPDUs(conn)
+	if err != nil {
+		return nil, 0, 0, false, fmt.Errorf("read pdus: %w", err)
+	}
+	if cacheReset {
+		return nil, 0, 0, false, nil
+	}
+
+	c.mu.RLock()
+	merged := applyDelta(c.roas, announced, withdrawn)
+	c.mu.RUnlock()
+
+	return merged, newSerial, newSessID, true, nil
+}
+
+func (c *Client) dial() (net.Conn, error) {
+	addr := fmt.Sprintf("%s:%d", c.Host, c.Port)
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", addr, err)
+	}
+	return conn, nil
+}
+
+// applyDelta returns base with every ROA in withdrawn removed and every ROA
+// in announced added, per RFC 8210 Serial Query semantics.
+func applyDelta(base, announced, withdrawn []ROA) []ROA {
+	merged := make([]ROA, 0, len(base)+len(announced))
+	for _, roa := range base {
+		if !containsROA(withdrawn, roa) {
+			merged = append(merged, roa)
+		}
+	}
+	return append(merged, announced...)
+}
+
+func containsROA(list []ROA, roa ROA) bool {
+	for _, r := range list {
+		if r.ASN == roa.ASN && r.MaxLen == roa.MaxLen && r.Prefix.String() == roa.Prefix.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// sendResetQuery writes a Reset Query PDU (RFC 8210 section 5.2).
+func sendResetQuery(conn net.Conn) error {
+	pdu := make([]byte, 8)
+	pdu[0] = 0 // protocol version
+	pdu[1] = pduResetQuery
+	binary.BigEndian.PutUint16(pdu[2:4], 0) // reserved
+	binary.BigEndian.PutUint32(pdu[4:8], 8) // length
+	_, err := conn.Write(pdu)
+	return err
+}
+
+// sendSerialQuery writes a Serial Query PDU (RFC 8210 section 5.3) asking
+// the cache for only the ROAs that changed since serial within sessID.
+func sendSerialQuery(conn net.Conn, sessID uint16, serial uint32) error {
+	pdu := make([]byte, 12)
+	pdu[0] = 0 // protocol version
+	pdu[1] = pduSerialQuery
+	binary.BigEndian.PutUint16(pdu[2:4], sessID)
+	binary.BigEndian.PutUint32(pdu[4:8], 12)
+	binary.BigEndian.PutUint32(pdu[8:12], serial)
+	_, err := conn.Write(pdu)
+	return err
+}
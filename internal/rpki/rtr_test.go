@@ -0,0 +1,45 @@
+package rpki
+
+import (
+	"net"
+	"testing"
+)
+
+func mustParseCIDR(t *testing.T, s string) *net.IPNet {
+	t.Helper()
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		t.Fatalf("parse CIDR %s: %v", s, err)
+	}
+	return n
+}
+
+func TestClientValidate(t *testing.T) {
+	c := &Client{
+		roas: []ROA{
+			{Prefix: mustParseCIDR(t, "203.0.113.0/24"), MaxLen: 26, ASN: 65001},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		prefix string
+		asn    uint32
+		want   Validity
+	}{
+		{"exact match is valid", "203.0.113.0/24", 65001, Valid},
+		{"more specific within max length is valid", "203.0.113.0/26", 65001, Valid},
+		{"covered but wrong origin is invalid", "203.0.113.0/24", 65002, Invalid},
+		{"covered but past max length is invalid", "203.0.113.0/28", 65001, Invalid},
+		{"uncovered prefix is unknown", "198.51.100.0/24", 65001, Unknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix := mustParseCIDR(t, tt.prefix)
+			if got := c.Validate(prefix, tt.asn); got != tt.want {
+				t.Errorf("Validate(%s, %d) = %s, want %s", tt.prefix, tt.asn, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+)
+
+// secretBytes is the amount of entropy used per generated secret, encoded
+// as URL-safe base64 for BIRD's quoted password syntax.
+const secretBytes = 24
+
+// Generate returns a new random session secret.
+func Generate() (string, error) {
+	buf := make([]byte, secretBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,28 @@
+package secrets
+
+import "sync"
+
+var (
+	storesMu sync.Mutex
+	stores   = map[string]*Store{}
+)
+
+// Shared returns the process-wide secrets store backed by path, loading it
+// on first use so every peer processor shares one in-memory copy (and one
+// set of file writes).
+func Shared(path string) (*Store, error) {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+
+	if s, ok := stores[path]; ok {
+		return s, nil
+	}
+
+	s, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	stores[path] = s
+
+	return s, nil
+}
@@ -0,0 +1,163 @@
+// Package secrets generates and persists per-session BGP authentication
+// secrets (MD5/TCP-AO passwords) so they stay stable across bcg runs and can
+// be rotated explicitly.
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Secret is one neighbor's authentication material.
+type Secret struct {
+	Asn       uint      `json:"asn"`
+	Neighbor  string    `json:"neighbor"`
+	Secret    string    `json:"secret"`
+	CreatedAt time.Time `json:"created_at"`
+	RotatedAt time.Time `json:"rotated_at,omitempty"`
+}
+
+// Store is a JSON-backed, mutex-guarded map of neighbor secrets, persisted
+// to disk with 0600 permissions so it isn't world-readable.
+type Store struct {
+	path string
+
+	mu      sync.Mutex
+	secrets map[string]*Secret
+}
+
+// Load reads a secrets store from path, creating an empty one if the file
+// doesn't exist yet.
+func Load(path string) (*Store, error) {
+	s := &Store{path: path, secrets: map[string]*Secret{}}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s.secrets); err != nil {
+		return nil, fmt.Errorf("parse secrets store %s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+// save writes the store back to disk at 0600. Callers must hold s.mu.
+func (s *Store) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("create secrets store directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(s.secrets, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(s.path, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Chmod(s.path, 0600)
+}
+
+func key(asn uint, neighbor string) string {
+	return fmt.Sprintf("%d/%s", asn, neighbor)
+}
+
+// GetOrCreate returns the existing secret for (asn, neighbor), generating
+// and persisting a new one if none exists yet.
+func (s *Store) GetOrCreate(asn uint, neighbor string) (*Secret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key(asn, neighbor)
+	if existing, ok := s.secrets[k]; ok {
+		return existing, nil
+	}
+
+	secret, err := Generate()
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &Secret{
+		Asn:       asn,
+		Neighbor:  neighbor,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}
+	s.secrets[k] = entry
+
+	if err := s.save(); err != nil {
+		return nil, fmt.Errorf("save secrets store: %w", err)
+	}
+
+	return entry, nil
+}
+
+// Rotate regenerates the secret for every neighbor belonging to asn and
+// returns the updated entries.
+func (s *Store) Rotate(asn uint) ([]*Secret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rotated []*Secret
+	for _, entry := range s.secrets {
+		if entry.Asn != asn {
+			continue
+		}
+
+		secret, err := Generate()
+		if err != nil {
+			return nil, err
+		}
+
+		entry.Secret = secret
+		entry.RotatedAt = time.Now()
+		rotated = append(rotated, entry)
+	}
+
+	if len(rotated) == 0 {
+		return nil, fmt.Errorf("no secrets found for AS%d", asn)
+	}
+
+	if err := s.save(); err != nil {
+		return nil, fmt.Errorf("save secrets store: %w", err)
+	}
+
+	return rotated, nil
+}
+
+// Export writes every secret in the given format ("json" or "yaml"),
+// suitable for handing to a peer out-of-band.
+func (s *Store) Export(w io.Writer, format string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch format {
+	case "", "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s.secrets)
+	case "yaml":
+		data, err := yaml.Marshal(s.secrets)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	default:
+		return fmt.Errorf("unsupported export format %q (want json or yaml)", format)
+	}
+}
@@ -1,43 +1,34 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
-	"io/ioutil"
-	"net/http"
+	"fmt"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path"
 	"path/filepath"
-	"strconv"
+	"runtime"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
-	"unicode"
 
 	"github.com/jessevdk/go-flags"
-	"github.com/kennygrant/sanitize"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/natesales/bcg/internal/bird"
 	"github.com/natesales/bcg/internal/config"
+	"github.com/natesales/bcg/internal/daemon"
+	"github.com/natesales/bcg/internal/processors"
+	"github.com/natesales/bcg/internal/rpki"
+	"github.com/natesales/bcg/internal/secrets"
 	"github.com/natesales/bcg/internal/templating"
 )
 
 var version = "dev" // set by the build process
 
-// PeeringDbResponse contains the response from a PeeringDB query
-type PeeringDbResponse struct {
-	Data []PeeringDbData `json:"data"`
-}
-
-// PeeringDbData contains the actual data from PeeringDB response
-type PeeringDbData struct {
-	Name    string `json:"name"`
-	AsSet   string `json:"irr_as_set"`
-	MaxPfx4 uint   `json:"info_prefixes4"`
-	MaxPfx6 uint   `json:"info_prefixes6"`
-}
-
 // Config constants
 const (
 	DefaultIPv4TableSize = 1000000
@@ -46,16 +37,26 @@ const (
 
 // Flags
 var opts struct {
-	ConfigFile       string `short:"c" long:"config" description:"Configuration file in YAML, TOML, or JSON format" default:"/etc/bcg/config.yml"`
-	Output           string `short:"o" long:"output" description:"Directory to write output files to" default:"/etc/bird/"`
-	Socket           string `short:"s" long:"socket" description:"BIRD control socket" default:"/run/bird/bird.ctl"`
-	KeepalivedConfig string `short:"k" long:"keepalived-config" description:"Configuration file for keepalived" default:"/etc/keepalived/keepalived.conf"`
-	UiFile           string `short:"u" long:"ui-file" description:"File to store web UI" default:"/tmp/bcg-ui.html"`
-	NoUi             bool   `short:"n" long:"no-ui" description:"Don't generate web UI"`
-	Verbose          bool   `short:"v" long:"verbose" description:"Show verbose log messages"`
-	DryRun           bool   `short:"d" long:"dry-run" description:"Don't modify BIRD config"`
-	NoConfigure      bool   `long:"no-configure" description:"Don't configure BIRD"`
-	ShowVersion      bool   `long:"version" description:"Show version and exit"`
+	ConfigFile       string        `short:"c" long:"config" description:"Configuration file in YAML, TOML, or JSON format" default:"/etc/bcg/config.yml"`
+	Output           string        `short:"o" long:"output" description:"Directory to write output files to" default:"/etc/bird/"`
+	Socket           string        `short:"s" long:"socket" description:"BIRD control socket" default:"/run/bird/bird.ctl"`
+	KeepalivedConfig string        `short:"k" long:"keepalived-config" description:"Configuration file for keepalived" default:"/etc/keepalived/keepalived.conf"`
+	UiFile           string        `short:"u" long:"ui-file" description:"File to store web UI" default:"/tmp/bcg-ui.html"`
+	NoUi             bool          `short:"n" long:"no-ui" description:"Don't generate web UI"`
+	Verbose          bool          `short:"v" long:"verbose" description:"Show verbose log messages"`
+	DryRun           bool          `short:"d" long:"dry-run" description:"Don't modify BIRD config"`
+	NoConfigure      bool          `long:"no-configure" description:"Don't configure BIRD"`
+	ShowVersion      bool          `long:"version" description:"Show version and exit"`
+	Daemon           bool          `long:"daemon" description:"Run continuously instead of exiting after one pass"`
+	Listen           string        `long:"listen" description:"Address for the daemon status/metrics HTTP server" default:":8080"`
+	Interval         time.Duration `long:"interval" description:"How often the daemon re-runs the pipeline" default:"5m"`
+	SecretsFile      string        `long:"secrets-file" description:"Where generated session secrets are persisted" default:"/var/lib/bcg/secrets.json"`
+	Concurrency      int           `long:"concurrency" description:"Number of peers to resolve concurrently" default:"0"`
+	CacheDir         string        `long:"cache-dir" description:"Directory to cache PeeringDB responses in, keyed by ASN"`
+	PeeringDbApiKey  string        `long:"peeringdb-api-key" description:"PeeringDB API key, sent as an Authorization: Api-Key header"`
+
+	RotateSecret  rotateSecretCommand  `command:"rotate-secret" description:"Rotate generated session secrets for an ASN"`
+	ExportSecrets exportSecretsCommand `command:"export-secrets" description:"Export generated session secrets"`
 }
 
 // Embedded filesystem
@@ -63,88 +64,57 @@ var opts struct {
 //go:embed templates/*
 var embedFs embed.FS
 
-// Query PeeringDB for an ASN
-func getPeeringDbData(asn uint) PeeringDbData {
-	httpClient := http.Client{Timeout: time.Second * 5}
-	req, err := http.NewRequest(http.MethodGet, "https://peeringdb.com/api/net?asn="+strconv.Itoa(int(asn)), nil)
-	if err != nil {
-		log.Fatalf("PeeringDB GET (This peer might not have a PeeringDB page): %v", err)
-	}
+// rotateSecretCommand implements `bcg rotate-secret --asn N`: it regenerates
+// every session secret on file for that ASN and prints the new bundle so it
+// can be handed to the peer out-of-band.
+type rotateSecretCommand struct {
+	Asn uint `long:"asn" required:"true" description:"ASN to rotate session secrets for"`
+}
 
-	res, err := httpClient.Do(req)
+func (c *rotateSecretCommand) Execute(args []string) error {
+	store, err := secrets.Load(opts.SecretsFile)
 	if err != nil {
-		log.Fatalf("PeeringDB GET Request: %v", err)
-	}
-
-	if res.Body != nil {
-		//noinspection GoUnhandledErrorResult
-		defer res.Body.Close()
+		return err
 	}
 
-	body, err := ioutil.ReadAll(res.Body)
+	rotated, err := store.Rotate(c.Asn)
 	if err != nil {
-		log.Fatalf("PeeringDB Read: %v", err)
+		return err
 	}
 
-	var peeringDbResponse PeeringDbResponse
-	if err := json.Unmarshal(body, &peeringDbResponse); err != nil {
-		log.Fatalf("PeeringDB JSON Unmarshal: %v", err)
-	}
+	log.Infof("Rotated %d secret(s) for AS%d", len(rotated), c.Asn)
 
-	if len(peeringDbResponse.Data) < 1 {
-		log.Fatalf("Peer %d doesn't have a valid PeeringDB entry. Try import-valid or ask the network to update their account.", asn)
+	if err := json.NewEncoder(os.Stdout).Encode(rotated); err != nil {
+		return err
 	}
 
-	return peeringDbResponse.Data[0]
+	// The command has already done its job; don't fall through to the
+	// normal render pipeline.
+	os.Exit(0)
+	return nil
+}
+
+// exportSecretsCommand implements `bcg export-secrets --format json|yaml`:
+// it prints every persisted session secret to stdout for handing to peers
+// out-of-band or backing up.
+type exportSecretsCommand struct {
+	Format string `long:"format" description:"Export format" choice:"json" choice:"yaml" default:"json"`
 }
 
-// Use bgpq4 to generate a prefix filter and return only the filter lines
-func getPrefixFilter(asSet string, family uint8, irrdb string) []string {
-	// Run bgpq4 for BIRD format with aggregation enabled
-	log.Infof("Running bgpq4 -h %s -Ab%d %s", irrdb, family, asSet)
-	cmd := exec.Command("bgpq4", "-h", irrdb, "-Ab"+strconv.Itoa(int(family)), asSet)
-	stdout, err := cmd.Output()
+func (c *exportSecretsCommand) Execute(args []string) error {
+	store, err := secrets.Load(opts.SecretsFile)
 	if err != nil {
-		log.Fatalf("bgpq4 error: %v", err.Error())
+		return err
 	}
 
-	// Remove whitespace and commas from output
-	output := strings.ReplaceAll(string(stdout), ",\n    ", "\n")
-
-	// Remove array prefix
-	output = strings.ReplaceAll(output, "NN = [\n    ", "")
-
-	// Remove array suffix
-	output = strings.ReplaceAll(output, "];", "")
-
-	// Check for empty IRR
-	if output == "" {
-		log.Warnf("Peer with as-set %s has no IPv%d prefixes. Disabled IPv%d connectivity.", asSet, family, family)
-		return []string{}
+	if err := store.Export(os.Stdout, c.Format); err != nil {
+		return err
 	}
 
-	// Remove whitespace (in this case there should only be trailing whitespace)
-	output = strings.TrimSpace(output)
-
-	// Split output by newline
-	return strings.Split(output, "\n")
-}
-
-// Normalize a string to be filename-safe
-func normalize(input string) string {
-	// Remove non-alphanumeric characters
-	input = sanitize.Path(input)
-
-	// Make uppercase
-	input = strings.ToUpper(input)
-
-	// Replace spaces with underscores
-	input = strings.ReplaceAll(input, " ", "_")
-
-	// Replace slashes with dashes
-	input = strings.ReplaceAll(input, "/", "-")
-
-	return input
+	// The command has already done its job; don't fall through to the
+	// normal render pipeline.
+	os.Exit(0)
+	return nil
 }
 
 func main() {
@@ -171,7 +141,6 @@ func main() {
 	log.Infof("Starting bcg %s", version)
 
 	// Parse template files
-
 	err = templating.Load(embedFs)
 	if err != nil {
 		log.Fatal(err)
@@ -179,11 +148,31 @@ func main() {
 
 	log.Debug("Finished loading templates")
 
+	if !opts.Daemon {
+		if _, err := run(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	d := daemon.New(opts.ConfigFile, opts.Interval, opts.Listen, opts.Socket, run)
+	if err := d.Run(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run loads the config file, runs the peer processor pipeline, and renders
+// every BIRD/keepalived/UI output file. It's called once for a one-shot
+// invocation, or repeatedly by the daemon.
+func run() (*config.Global, error) {
 	// Load the config file from configFilename flag
 	log.Debugf("Loading config from %s", opts.ConfigFile)
 	globalConfig, err := config.Load(opts.ConfigFile)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	if len(globalConfig.Prefixes) == 0 {
@@ -214,43 +203,87 @@ func main() {
 		log.Debug("Creating global config")
 		globalFile, err := os.Create(path.Join(opts.Output, "bird.conf"))
 		if err != nil {
-			log.Fatalf("Create global BIRD output file: %v", err)
+			return nil, fmt.Errorf("create global BIRD output file: %v", err)
 		}
 		log.Debug("Finished creating global config file")
 
 		// Render the global template and write to disk
 		log.Debug("Writing global config file")
-		err = templating.GlobalTemplate.ExecuteTemplate(globalFile, "global.tmpl", globalConfig)
-		if err != nil {
-			log.Fatalf("Execute global template: %v", err)
+		if err := templating.GlobalTemplate.ExecuteTemplate(globalFile, "global.tmpl", globalConfig); err != nil {
+			return nil, fmt.Errorf("execute global template: %v", err)
 		}
 		log.Debug("Finished writing global config file")
 
 		// Remove old peer-specific configs
 		files, err := filepath.Glob(path.Join(opts.Output, "AS*.conf"))
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 		for _, f := range files {
 			if err := os.Remove(f); err != nil {
-				log.Fatalf("Removing old config files: %v", err)
+				return nil, fmt.Errorf("removing old config files: %v", err)
 			}
 		}
 	} else {
 		log.Info("Dry run is enabled, skipped writing global config and removing old peer configs")
 	}
 
-	// Iterate over peers
-	for peerName, peerData := range globalConfig.Peers {
-		// Add peer prefix if the first character of peerName is a number
-		_peerName := strings.ReplaceAll(normalize(peerName), "-", "_")
-		if unicode.IsDigit(rune(_peerName[0])) {
-			_peerName = "PEER_" + _peerName
+	// Start the RPKI RTR client, if configured, and re-render the roa4/
+	// roa6 tables whenever the validator sends us an update.
+	if globalConfig.RPKI.Host != "" && !opts.DryRun {
+		rtrClient, err := rpki.Shared(globalConfig.RPKI.Host, globalConfig.RPKI.Port, globalConfig.RPKI.RefreshInterval)
+		if err != nil {
+			return nil, fmt.Errorf("connect to RPKI cache: %v", err)
 		}
 
-		// Set normalized peer name
-		peerData.Name = _peerName
+		writeRoaTables := func(roas []rpki.ROA) {
+			roaFile, err := os.Create(path.Join(opts.Output, "roa.conf"))
+			if err != nil {
+				log.Errorf("Create RPKI roa output file: %v", err)
+				return
+			}
+			defer roaFile.Close() //nolint:errcheck
+
+			if err := rpki.WriteTables(roaFile, roas); err != nil {
+				log.Errorf("Write RPKI roa tables: %v", err)
+			}
+		}
+		rtrClient.SetOnUpdate(writeRoaTables)
+		writeRoaTables(rtrClient.ROAs())
+	}
+
+	// Build the peer resolution pipeline: PeeringDB lookup, IRR prefix
+	// filter generation, RPKI ROA cross-check, secret generation, and
+	// finally template rendering. Each stage reports its own per-peer
+	// status. globalConfig.Processors can switch off the PeeringDB and IRR
+	// stages; RPKI runs only when an RPKI host is configured, secret
+	// generation only for peers with generate_password, and rendering
+	// always runs.
+	pipeline := processors.NewPipeline(
+		&processors.PeeringDBProcessor{
+			APIKey:   opts.PeeringDbApiKey,
+			CacheDir: opts.CacheDir,
+		},
+		&processors.IRRProcessor{},
+		&processors.RPKIProcessor{},
+		&processors.SecretGenProcessor{SecretsFile: opts.SecretsFile},
+		&processors.RenderProcessor{OutputDir: opts.Output, DryRun: opts.DryRun},
+	)
+
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = runtime.NumCPU()
+	}
 
+	// Fan peer resolution out over a bounded worker pool so a config with
+	// many peers doesn't pay each peer's PeeringDB/bgpq4 latency serially.
+	// A peer that fails validation is skipped and logged rather than
+	// aborting the run, so one bad entry in a large config doesn't keep
+	// every other peer's BIRD config, VRRP config, and UI from being
+	// written.
+	var badPeerMu sync.Mutex
+	var badPeers []string
+	processors.ForEachPeer(concurrency, globalConfig, func(peerName string, peerData *config.Peer) {
 		// Set default query time
 		peerData.QueryTime = "[No operations performed]"
 
@@ -258,58 +291,18 @@ func main() {
 
 		// Validate peer type
 		if !(peerData.Type == "upstream" || peerData.Type == "peer" || peerData.Type == "downstream" || peerData.Type == "import-valid") {
-			log.Fatalf("[%s] type attribute is invalid. Must be upstream, peer, downstream, or import-valid", peerName)
+			log.Errorf("[%s] type attribute is invalid. Must be upstream, peer, downstream, or import-valid; skipping this peer", peerName)
+			badPeerMu.Lock()
+			badPeers = append(badPeers, peerName)
+			badPeerMu.Unlock()
+			return
 		}
 
 		log.Infof("[%s] type: %s", peerName, peerData.Type)
 
-		// Only query PeeringDB and IRRDB for peers and downstreams, TODO: This should validate upstreams too
-		if peerData.Type == "peer" || peerData.Type == "downstream" {
-			peerData.QueryTime = time.Now().Format(time.RFC1123)
-			peeringDbData := getPeeringDbData(peerData.Asn)
-
-			if peerData.ImportLimit4 == 0 {
-				peerData.ImportLimit4 = peeringDbData.MaxPfx4
-				log.Infof("[%s] has no IPv4 import limit configured. Setting to %d from PeeringDB", peerName, peeringDbData.MaxPfx4)
-			}
-
-			if peerData.ImportLimit6 == 0 {
-				peerData.ImportLimit6 = peeringDbData.MaxPfx6
-				log.Infof("[%s] has no IPv6 import limit configured. Setting to %d from PeeringDB", peerName, peeringDbData.MaxPfx6)
-			}
-
-			// Only set AS-SET from PeeringDB if it isn't configure manually
-			if peerData.AsSet == "" {
-				// If the as-set has a space in it, split and pick the first element
-				if strings.Contains(peeringDbData.AsSet, " ") {
-					peeringDbData.AsSet = strings.Split(peeringDbData.AsSet, " ")[0]
-					log.Warnf("[%s] has a space in their PeeringDB as-set field. Selecting first element %s", peerName, peeringDbData.AsSet)
-				}
-
-				// Trim IRRDB prefix
-				if strings.Contains(peeringDbData.AsSet, "::") {
-					peerData.AsSet = strings.Split(peeringDbData.AsSet, "::")[1]
-					log.Warnf("[%s] has a IRRDB prefix in their PeeringDB as-set field. Using %s", peerName, peerData.AsSet)
-				} else {
-					peerData.AsSet = peeringDbData.AsSet
-				}
-
-				if peeringDbData.AsSet == "" {
-					log.Fatalf("[%s] has no as-set in PeeringDB", peerName)
-				} else {
-					log.Infof("[%s] has no manual AS-SET defined. Setting to %s from PeeringDB\n", peerName, peeringDbData.AsSet)
-				}
-			} else {
-				log.Infof("[%s] has manual AS-SET: %s", peerName, peerData.AsSet)
-			}
-
-			peerData.PrefixSet4 = getPrefixFilter(peerData.AsSet, 4, globalConfig.IrrDb)
-			peerData.PrefixSet6 = getPrefixFilter(peerData.AsSet, 6, globalConfig.IrrDb)
-
-			// Update the "latest operation" timestamp
-			peerData.QueryTime = time.Now().Format(time.RFC1123)
-		} else if peerData.Type == "upstream" || peerData.Type == "import-valid" {
-			// Check for a zero prefix import limit
+		// Default import limits for peer types that don't go through the
+		// PeeringDB processor
+		if peerData.Type == "upstream" || peerData.Type == "import-valid" {
 			if peerData.ImportLimit4 == 0 {
 				peerData.ImportLimit4 = DefaultIPv4TableSize
 				log.Infof("[%s] has no IPv4 import limit configured. Setting to %d", peerName, DefaultIPv4TableSize)
@@ -321,9 +314,22 @@ func main() {
 			}
 		}
 
-		// If as-set is empty and the peer type requires it
-		if peerData.AsSet == "" && (peerData.Type == "peer" || peerData.Type == "downstream") {
-			log.Fatal("[%s] has no AS-SET defined and filtering profile requires it.", peerName)
+		peerData.QueryTime = time.Now().Format(time.RFC1123)
+		pipeline.Run(context.Background(), peerName, peerData, globalConfig)
+		peerData.QueryTime = time.Now().Format(time.RFC1123)
+
+		// A failed PeeringDB or IRR lookup leaves the peer without the
+		// prefix filters its type requires; rendering and applying a
+		// config for it would push a live session with broken filtering,
+		// so skip it the same way an invalid peer type is skipped.
+		for _, name := range []string{"peeringdb", "irr"} {
+			if status, ok := peerData.Status[name]; ok && status.Err != nil {
+				log.Errorf("[%s] processor %s failed; skipping this peer", peerName, name)
+				badPeerMu.Lock()
+				badPeers = append(badPeers, peerName)
+				badPeerMu.Unlock()
+				return
+			}
 		}
 
 		// Print peer info
@@ -372,25 +378,16 @@ func main() {
 
 		// Log neighbor IPs
 		log.Infof("[%s] neighbors: %s", peerName, strings.Join(peerData.NeighborIPs, ", "))
+	})
 
-		if !opts.DryRun {
-			// Create the peer specific file
-			peerSpecificFile, err := os.Create(path.Join(opts.Output, "AS"+strconv.Itoa(int(peerData.Asn))+"_"+normalize(peerName)+".conf"))
-			if err != nil {
-				log.Fatalf("Create peer specific output file: %v", err)
-			}
-
-			// Render the template and write to disk
-			log.Infof("[%s] Writing config", peerName)
-			err = templating.PeerTemplate.ExecuteTemplate(peerSpecificFile, "peer.tmpl", &config.Wrapper{Peer: *peerData, Config: *globalConfig})
-			if err != nil {
-				log.Fatalf("Execute template: %v", err)
-			}
-
-			log.Infof("[%s] Wrote config", peerName)
-		} else {
-			log.Infof("Dry run is enabled, skipped writing peer config(s)")
-		}
+	// Drop peers that failed validation so the global/VRRP/UI templates
+	// never see them; their BIRD config is simply absent rather than
+	// broken.
+	for _, peerName := range badPeers {
+		delete(globalConfig.Peers, peerName)
+	}
+	if len(badPeers) > 0 {
+		log.Warnf("Skipped %d invalid peer(s): %s", len(badPeers), strings.Join(badPeers, ", "))
 	}
 
 	// Write VRRP config
@@ -398,13 +395,12 @@ func main() {
 		// Create the peer specific file
 		peerSpecificFile, err := os.Create(path.Join(opts.KeepalivedConfig))
 		if err != nil {
-			log.Fatalf("Create peer specific output file: %v", err)
+			return nil, fmt.Errorf("create peer specific output file: %v", err)
 		}
 
 		// Render the template and write to disk
-		err = templating.VRRPTemplate.ExecuteTemplate(peerSpecificFile, "vrrp.tmpl", globalConfig.VRRPInstances)
-		if err != nil {
-			log.Fatalf("Execute template: %v", err)
+		if err := templating.VRRPTemplate.ExecuteTemplate(peerSpecificFile, "vrrp.tmpl", globalConfig.VRRPInstances); err != nil {
+			return nil, fmt.Errorf("execute template: %v", err)
 		}
 	} else {
 		log.Infof("Dry run is enabled, not writing VRRP config")
@@ -416,26 +412,27 @@ func main() {
 			log.Debug("Creating global config")
 			uiFileObj, err := os.Create(opts.UiFile)
 			if err != nil {
-				log.Fatalf("Create UI output file: %v", err)
+				return nil, fmt.Errorf("create UI output file: %v", err)
 			}
 			log.Debug("Finished creating UI file")
 
 			// Render the UI template and write to disk
 			log.Debug("Writing ui file")
-			err = templating.UiTemplate.ExecuteTemplate(uiFileObj, "ui.tmpl", globalConfig)
-			if err != nil {
-				log.Fatalf("Execute ui template: %v", err)
+			if err := templating.UiTemplate.ExecuteTemplate(uiFileObj, "ui.tmpl", globalConfig); err != nil {
+				return nil, fmt.Errorf("execute ui template: %v", err)
 			}
 			log.Debug("Finished writing ui file")
 		}
 
 		if !opts.NoConfigure {
 			log.Infoln("reconfiguring bird")
-			if err = bird.RunCommand("configure", opts.Socket); err != nil {
-				log.Fatal(err)
+			if err := bird.RunCommand("configure", opts.Socket); err != nil {
+				return nil, err
 			}
 		} else {
 			log.Infoln("noreconfig is set, NOT reconfiguring bird")
 		}
 	}
+
+	return globalConfig, nil
 }